@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// atomicFile writes to a temporary file that replaces the destination file when it is closed.
+// The previous version of the destination file is kept as a backup with a .bak extension.
+type atomicFile struct {
+	*os.File
+	name string
+	dir  string
+}
+
+// newFileMode is the permissions of new files that are not replacing other files
+const newFileMode os.FileMode = 0644
+
+// createAtomicFile creates a temporary file in the directory of the named file that will replace it when closed.
+// The temporary file has the permissions of the file it replaces.
+func createAtomicFile(name string) (*atomicFile, error) {
+	dir, base := filepath.Split(name)
+	if len(dir) == 0 {
+		dir = "."
+	}
+	mode := newFileMode
+	if info, err := os.Stat(name); err == nil {
+		mode = info.Mode().Perm()
+	}
+	f, err := os.CreateTemp(dir, "."+base+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("creating temporary file: %v", err)
+	}
+	if err := f.Chmod(mode); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, fmt.Errorf("setting permissions of temporary file: %v", err)
+	}
+	af := atomicFile{
+		File: f,
+		name: name,
+		dir:  dir,
+	}
+	return &af, nil
+}
+
+// Close flushes the temporary file to the disk, backs up the destination file, and renames the temporary file to the destination.
+// The temporary file is removed if any step fails, leaving the destination file unchanged.
+// The directory is flushed after the rename so the replacement survives a crash.
+func (f *atomicFile) Close() error {
+	tmpName := f.File.Name()
+	if err := f.File.Sync(); err != nil {
+		f.Abort()
+		return fmt.Errorf("syncing temporary file: %v", err)
+	}
+	if err := f.File.Close(); err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("closing temporary file: %v", err)
+	}
+	if err := backupFile(f.name); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	if err := os.Rename(tmpName, f.name); err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("replacing file: %v", err)
+	}
+	if err := syncDir(f.dir); err != nil {
+		return fmt.Errorf("syncing folder of replaced file: %v", err)
+	}
+	return nil
+}
+
+// syncDir flushes the entries of the directory to the disk.
+// Directories cannot be synced on windows, where renames are flushed by the file system.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	if err := d.Sync(); err != nil {
+		d.Close()
+		return err
+	}
+	return d.Close()
+}
+
+// Abort discards the temporary file without replacing the destination file
+func (f *atomicFile) Abort() error {
+	f.File.Close()
+	return os.Remove(f.File.Name())
+}
+
+// backupFile copies the file to a .bak file, replacing the previous backup.
+// The file is copied rather than renamed so the file always exists.
+// Files that do not exist are not backed up.
+func backupFile(name string) error {
+	src, err := os.Open(name)
+	switch {
+	case os.IsNotExist(err):
+		return nil
+	case err != nil:
+		return fmt.Errorf("opening file to back up: %v", err)
+	}
+	defer src.Close()
+	dest, err := os.Create(name + ".bak")
+	if err != nil {
+		return fmt.Errorf("creating backup file: %v", err)
+	}
+	if _, err := io.Copy(dest, src); err != nil {
+		dest.Close()
+		return fmt.Errorf("copying to backup file: %v", err)
+	}
+	if err := dest.Sync(); err != nil {
+		dest.Close()
+		return fmt.Errorf("syncing backup file: %v", err)
+	}
+	if err := dest.Close(); err != nil {
+		return fmt.Errorf("closing backup file: %v", err)
+	}
+	return nil
+}
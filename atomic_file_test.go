@@ -0,0 +1,136 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestAtomicFile(t *testing.T) {
+	tests := []struct {
+		name       string
+		previous   string // not created if empty
+		abort      bool
+		wantFile   string
+		wantBackup string // not wanted if empty
+	}{
+		{
+			name:     "new file",
+			wantFile: "new",
+		},
+		{
+			name:       "replace file",
+			previous:   "old",
+			wantFile:   "new",
+			wantBackup: "old",
+		},
+		{
+			name:     "abort new file",
+			abort:    true,
+			wantFile: "",
+		},
+		{
+			name:     "abort replace file",
+			previous: "old",
+			abort:    true,
+			wantFile: "old",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			name := filepath.Join(dir, "list.m3u")
+			if len(test.previous) != 0 {
+				if err := os.WriteFile(name, []byte(test.previous), 0644); err != nil {
+					t.Fatalf("writing previous file: %v", err)
+				}
+			}
+			f, err := createAtomicFile(name)
+			if err != nil {
+				t.Fatalf("unwanted error: %v", err)
+			}
+			if _, err := f.Write([]byte("new")); err != nil {
+				t.Fatalf("unwanted write error: %v", err)
+			}
+			if test.abort {
+				err = f.Abort()
+			} else {
+				err = f.Close()
+			}
+			if err != nil {
+				t.Fatalf("unwanted error: %v", err)
+			}
+			checkFile := func(name, want string) {
+				t.Helper()
+				got, err := os.ReadFile(name)
+				switch {
+				case len(want) == 0:
+					if !os.IsNotExist(err) {
+						t.Errorf("wanted %q to not exist, got %q, %v", name, got, err)
+					}
+				case err != nil:
+					t.Errorf("reading %q: %v", name, err)
+				case want != string(got):
+					t.Errorf("contents of %q not equal: wanted %q, got %q", name, want, got)
+				}
+			}
+			checkFile(name, test.wantFile)
+			checkFile(name+".bak", test.wantBackup)
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatalf("reading dir: %v", err)
+			}
+			for _, e := range entries {
+				if filepath.Ext(e.Name()) == ".tmp" {
+					t.Errorf("temporary file not removed: %v", e.Name())
+				}
+			}
+		})
+	}
+	t.Run("permissions", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("windows files do not have unix permissions")
+		}
+		dir := t.TempDir()
+		for _, test := range []struct {
+			name     string
+			previous os.FileMode // not created if zero
+			want     os.FileMode
+		}{
+			{"new.m3u", 0, 0644},
+			{"shared.m3u", 0664, 0664},
+			{"private.m3u", 0600, 0600},
+		} {
+			name := filepath.Join(dir, test.name)
+			if test.previous != 0 {
+				if err := os.WriteFile(name, []byte("old"), test.previous); err != nil {
+					t.Fatalf("writing previous file: %v", err)
+				}
+				if err := os.Chmod(name, test.previous); err != nil { // not limited by the umask
+					t.Fatalf("setting permissions of previous file: %v", err)
+				}
+			}
+			f, err := createAtomicFile(name)
+			if err != nil {
+				t.Fatalf("unwanted error: %v", err)
+			}
+			if err := f.Close(); err != nil {
+				t.Fatalf("unwanted error: %v", err)
+			}
+			info, err := os.Stat(name)
+			if err != nil {
+				t.Fatalf("unwanted error: %v", err)
+			}
+			if got := info.Mode().Perm(); test.want != got {
+				t.Errorf("permissions of %v not equal: wanted %v, got %v", test.name, test.want, got)
+			}
+		}
+	})
+	t.Run("missing dir", func(t *testing.T) {
+		name := filepath.Join(t.TempDir(), "missing", "list.m3u")
+		if _, err := createAtomicFile(name); err == nil {
+			t.Error("wanted error")
+		}
+	})
+}
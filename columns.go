@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// songColumn is an optional column of the song and track tables
+type songColumn struct {
+	header string
+	value  func(s song) string
+}
+
+// songColumns are the optional columns of the song and track tables, by name
+var songColumns = map[string]songColumn{
+	"albumartist": {"Album Artist", func(s song) string { return s.albumArtist }},
+	"composer":    {"Composer", func(s song) string { return s.composer }},
+	"genre":       {"Genre", func(s song) string { return s.genre }},
+	"comment":     {"Comment", func(s song) string { return s.comment }},
+	"year":        {"Year", func(s song) string { return formatPositive(s.year) }},
+	"disc":        {"Disc", func(s song) string { return formatPositive(s.disc) }},
+	"root":        {"Root", func(s song) string { return s.root }},
+}
+
+// setColumns chooses the optional columns to display in the song and track tables: cols [name,...].
+// The optional columns are removed if no names are given.
+func (p *playlist) setColumns(command string) {
+	names := strings.FieldsFunc(strings.ToLower(command), func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+	for _, name := range names {
+		if _, ok := songColumns[name]; !ok {
+			p.fail("Error (columns): unknown column %q (wanted one of %v)\n", name, songColumnNames())
+			return
+		}
+	}
+	p.columns = names
+}
+
+// songColumnNames lists the sorted names of the optional columns
+func songColumnNames() string {
+	names := make([]string, 0, len(songColumns))
+	for name := range songColumns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// columnsFormat creates the format of the optional columns for the songs, which are left-aligned
+func (p *playlist) columnsFormat(songs []song) string {
+	var sb strings.Builder
+	for _, name := range p.columns {
+		c := songColumns[name]
+		maxW := len(c.header)
+		for _, s := range songs {
+			if w := len(c.value(s)); maxW < w {
+				maxW = w
+			}
+		}
+		fmt.Fprintf(&sb, "%%-%dv    ", maxW)
+	}
+	return sb.String()
+}
+
+// columnRow creates the values of a table row, with the optional column values inserted after the first values
+func (p *playlist) columnRow(columnValue func(c songColumn) string, first []interface{}, last ...interface{}) []interface{} {
+	row := append([]interface{}{}, first...)
+	for _, name := range p.columns {
+		row = append(row, columnValue(songColumns[name]))
+	}
+	return append(row, last...)
+}
+
+// formatPositive formats the number, or returns an empty string if it is not positive
+func formatPositive(i int) string {
+	if i <= 0 {
+		return ""
+	}
+	return strconv.Itoa(i)
+}
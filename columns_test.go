@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestPlaylistSetColumns(t *testing.T) {
+	tests := []struct {
+		command string
+		want    string
+		wantErr bool
+	}{
+		{"", "[]", false},
+		{"genre", "[genre]", false},
+		{"Year, disc albumartist", "[year disc albumartist]", false},
+		{"genre,rating", "[composer]", true},
+	}
+	for _, test := range tests {
+		t.Run(test.command, func(t *testing.T) {
+			var w bytes.Buffer
+			p := playlist{
+				w:       &w,
+				columns: []string{"composer"},
+			}
+			p.setColumns(test.command)
+			switch {
+			case test.wantErr != (w.Len() != 0):
+				t.Errorf("wanted error: %v, got %q", test.wantErr, w.String())
+			case test.want != fmt.Sprint(p.columns):
+				t.Errorf("columns not equal: wanted %v, got %v", test.want, p.columns)
+			}
+		})
+	}
+}
+
+func TestPlaylistPrintColumns(t *testing.T) {
+	songs := []song{
+		{artist: "a", album: "b", title: "c", genre: "Alternative Rock", year: 2005},
+		{artist: "d", album: "e", title: "f", disc: 2},
+	}
+	t.Run("songs", func(t *testing.T) {
+		var w bytes.Buffer
+		p := playlist{
+			selection: songs,
+			columns:   []string{"genre", "year", "disc"},
+			w:         &w,
+		}
+		p.printSongFilter("")
+		want := "ID    Artist    Album    Genre               Year    Disc    Length    Title\n" +
+			" 1    a         b        Alternative Rock    2005                 ?    c\n" +
+			" 2    d         e                                    2            ?    f\n"
+		if got := w.String(); want != got {
+			t.Errorf("tables not equal: \n wanted: \n%v \n got: \n%v", want, got)
+		}
+	})
+	t.Run("tracks", func(t *testing.T) {
+		var w bytes.Buffer
+		p := playlist{
+			tracks:  []m3uTrack{{song: songs[0], display: "x"}},
+			columns: []string{"year"},
+			w:       &w,
+		}
+		p.printTracks("")
+		want := "Index    Display    Artist    Album    Year    Length    Title\n" +
+			"    1    x          a         b        2005         ?    c\n"
+		if got := w.String(); want != got {
+			t.Errorf("tables not equal: \n wanted: \n%v \n got: \n%v", want, got)
+		}
+	})
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// duplicateKeys identify songs that are the same, by name.
+// Songs with empty keys are not duplicates of any other songs.
+var duplicateKeys = map[string]func(s song) string{
+	"path": func(s song) string {
+		return s.path
+	},
+	"hash": func(s song) string {
+		return s.hash
+	},
+	"tags": func(s song) string {
+		artist, title := normalizeTag(s.artist), normalizeTag(s.title)
+		if len(artist) == 0 || len(title) == 0 {
+			return ""
+		}
+		return artist + " - " + title
+	},
+}
+
+// normalizeTag lowercases the tag, keeping only letters and numbers separated by single spaces
+func normalizeTag(tag string) string {
+	words := strings.FieldsFunc(strings.ToLower(tag), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	return strings.Join(words, " ")
+}
+
+// duplicateKey reads the name of the key that identifies duplicate songs, which must be loaded
+func (p *playlist) duplicateKey(name string) (func(s song) string, error) {
+	key, ok := duplicateKeys[name]
+	switch {
+	case !ok:
+		names := make([]string, 0, len(duplicateKeys))
+		for name := range duplicateKeys {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown key %q (wanted one of %v)", name, strings.Join(names, ", "))
+	case name == "hash" && !p.showHash:
+		return nil, fmt.Errorf("song hashes are only loaded when the application is run with -md5 or -hash")
+	}
+	return key, nil
+}
+
+// dedupe removes tracks that are duplicates of earlier tracks in the playlist: dedupe [path|hash|tags].
+// Tracks are compared by path by default.
+func (p *playlist) dedupe(command string) {
+	name := strings.TrimSpace(command)
+	if len(name) == 0 {
+		name = "path"
+	}
+	key, err := p.duplicateKey(name)
+	if err != nil {
+		p.fail("Error (dedupe): %v\n", err)
+		return
+	}
+	seen := make(map[string]struct{}, len(p.tracks))
+	tracks := make([]m3uTrack, 0, len(p.tracks))
+	for _, t := range p.tracks {
+		k := key(t.song)
+		if _, ok := seen[k]; ok && len(k) != 0 {
+			continue
+		}
+		seen[k] = struct{}{}
+		tracks = append(tracks, t)
+	}
+	removed := len(p.tracks) - len(tracks)
+	if removed != 0 {
+		p.record(fmt.Sprintf("dedupe %v tracks by %v", removed, name))
+		p.tracks = tracks
+	}
+	fmt.Fprintf(p.w, "removed %v duplicate tracks\n", removed)
+}
+
+// printDuplicates lists the groups of songs in the library that are duplicates: dupes [hash|tags].
+// Songs are compared by tags and also by hash if hashes are loaded.
+func (p *playlist) printDuplicates(command string) {
+	names := []string{strings.TrimSpace(command)}
+	switch {
+	case len(names[0]) != 0:
+		// NOOP
+	case p.showHash:
+		names = []string{"hash", "tags"}
+	default:
+		names = []string{"tags"}
+	}
+	groupCount := 0
+	for _, name := range names {
+		if name == "path" {
+			p.fail("Error (dupes): songs in the library have different paths, use hash or tags\n")
+			return
+		}
+		key, err := p.duplicateKey(name)
+		if err != nil {
+			p.fail("Error (dupes): %v\n", err)
+			return
+		}
+		for _, g := range duplicateGroups(p.songs, key) {
+			fmt.Fprintf(p.w, "%v %v: %v songs\n", name, key(g[0]), len(g))
+			for _, s := range g {
+				fmt.Fprintf(p.w, "    %v    %v\n", s.path, s.display())
+			}
+			groupCount++
+		}
+	}
+	fmt.Fprintf(p.w, "%v groups of duplicate songs\n", groupCount)
+}
+
+// duplicateGroups groups the songs with the same key, in the order of their first songs.
+// Only groups of multiple songs are included.
+func duplicateGroups(songs []song, key func(s song) string) [][]song {
+	var groups [][]song
+	groupIndexes := make(map[string]int)
+	for _, s := range songs {
+		k := key(s)
+		if len(k) == 0 {
+			continue
+		}
+		i, ok := groupIndexes[k]
+		if !ok {
+			i = len(groups)
+			groups = append(groups, nil)
+			groupIndexes[k] = i
+		}
+		groups[i] = append(groups[i], s)
+	}
+	duplicates := groups[:0]
+	for _, g := range groups {
+		if len(g) > 1 {
+			duplicates = append(duplicates, g)
+		}
+	}
+	return duplicates
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeTag(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want string
+	}{
+		{"", ""},
+		{"Beck", "beck"},
+		{"  Guns N' Roses ", "guns n roses"},
+		{"AC/DC", "ac dc"},
+		{"Sigur Rós", "sigur rós"},
+		{"(Don't Fear) The Reaper", "don t fear the reaper"},
+	}
+	for _, test := range tests {
+		if got := normalizeTag(test.tag); test.want != got {
+			t.Errorf("normalized tags of %q not equal: wanted %q, got %q", test.tag, test.want, got)
+		}
+	}
+}
+
+func TestPlaylistDedupe(t *testing.T) {
+	tracks := []m3uTrack{
+		{display: "1", song: song{path: "a.mp3", artist: "x", title: "y", hash: "1"}},
+		{display: "2", song: song{path: "b.mp3", artist: "X", title: "Y!", hash: "2"}},
+		{display: "3", song: song{path: "a.mp3", artist: "x", title: "y", hash: "1"}},
+		{display: "4", song: song{path: "c.mp3", artist: "z", title: "w", hash: "1"}},
+		{display: "5", song: song{path: "d.mp3", hash: "5"}},
+		{display: "6", song: song{path: "e.mp3", hash: "6"}},
+	}
+	tests := []struct {
+		command  string
+		showHash bool
+		want     string
+		wantErr  bool
+	}{
+		{"", false, "[1 2 4 5 6]", false},
+		{"path", false, "[1 2 4 5 6]", false},
+		{"tags", false, "[1 4 5 6]", false},
+		{"hash", true, "[1 2 5 6]", false},
+		{"hash", false, "[1 2 3 4 5 6]", true},
+		{"title", false, "[1 2 3 4 5 6]", true},
+	}
+	for _, test := range tests {
+		t.Run(test.command, func(t *testing.T) {
+			var w bytes.Buffer
+			p := playlist{
+				tracks:   copyTracks(tracks),
+				w:        &w,
+				showHash: test.showHash,
+			}
+			p.dedupe(test.command)
+			switch {
+			case test.wantErr != strings.HasPrefix(w.String(), "Error"):
+				t.Errorf("wanted error: %v, got %q", test.wantErr, w.String())
+			case test.want != fmt.Sprint(trackDisplays(p.tracks)):
+				t.Errorf("tracks not equal: wanted %v, got %v", test.want, trackDisplays(p.tracks))
+			case !test.wantErr && len(p.history.undo) != 1:
+				t.Errorf("wanted dedupe to be recorded")
+			}
+		})
+	}
+	t.Run("no duplicates", func(t *testing.T) {
+		var w bytes.Buffer
+		p := playlist{
+			tracks: copyTracks(tracks[:2]),
+			w:      &w,
+		}
+		p.dedupe("")
+		if len(p.history.undo) != 0 || p.dirty {
+			t.Errorf("unwanted recorded change")
+		}
+	})
+}
+
+func TestPlaylistPrintDuplicates(t *testing.T) {
+	songs := []song{
+		{path: "a.mp3", artist: "x", title: "y", hash: "1"},
+		{path: "b.mp3", artist: "X", title: "Y!", hash: "2"},
+		{path: "c.mp3", artist: "z", title: "w", hash: "1"},
+		{path: "d.mp3", hash: "4"},
+		{path: "e.mp3", title: "y", hash: "5"},
+	}
+	tests := []struct {
+		name     string
+		command  string
+		showHash bool
+		want     string
+	}{
+		{
+			name: "tags",
+			want: "tags x - y: 2 songs\n" +
+				"    a.mp3    x - y\n" +
+				"    b.mp3    X - Y!\n" +
+				"1 groups of duplicate songs\n",
+		},
+		{
+			name:     "hash and tags",
+			showHash: true,
+			want: "hash 1: 2 songs\n" +
+				"    a.mp3    x - y\n" +
+				"    c.mp3    z - w\n" +
+				"tags x - y: 2 songs\n" +
+				"    a.mp3    x - y\n" +
+				"    b.mp3    X - Y!\n" +
+				"2 groups of duplicate songs\n",
+		},
+		{
+			name:     "only hash",
+			command:  "hash",
+			showHash: true,
+			want: "hash 1: 2 songs\n" +
+				"    a.mp3    x - y\n" +
+				"    c.mp3    z - w\n" +
+				"1 groups of duplicate songs\n",
+		},
+		{
+			name:    "path",
+			command: "path",
+			want:    "Error (dupes): songs in the library have different paths, use hash or tags\n",
+		},
+		{
+			name:    "hash not loaded",
+			command: "hash",
+			want:    "Error (dupes): song hashes are only loaded when the application is run with -md5 or -hash\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var w bytes.Buffer
+			p := playlist{
+				songs:    songs,
+				w:        &w,
+				showHash: test.showHash,
+			}
+			p.printDuplicates(test.command)
+			if want, got := test.want, w.String(); want != got {
+				t.Errorf("output not equal: \n wanted: %q \n got:    %q", want, got)
+			}
+		})
+	}
+}
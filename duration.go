@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	// mp3FrameSearchLimit is the number of bytes after the ID3v2 tag that are checked for the first mp3 frame
+	mp3FrameSearchLimit = 64 * 1024
+	// mp3ConstantBitrateFrames is the number of frames that must have the same bitrate to estimate the duration from the size of the audio instead of counting all of the frames
+	mp3ConstantBitrateFrames = 8
+)
+
+type mp3Frame struct {
+	mpeg1      bool
+	layer      int
+	bitrate    int // bits per second
+	sampleRate int
+	padding    int
+	mono       bool
+}
+
+var (
+	mp3Bitrates = map[bool][4][16]int{ // kbps by mpeg1, layer, and index
+		true: {
+			1: {0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448},
+			2: {0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384},
+			3: {0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+		},
+		false: {
+			1: {0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256},
+			2: {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+			3: {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+		},
+	}
+	mp3SampleRates = [4][3]int{ // by version bits and index
+		0: {11025, 12000, 8000}, // MPEG 2.5
+		2: {22050, 24000, 16000},
+		3: {44100, 48000, 32000},
+	}
+)
+
+// parseMP3Frame reads the frame header at the start of the bytes
+func parseMP3Frame(b []byte) (f mp3Frame, ok bool) {
+	if len(b) < 4 || b[0] != 0xFF || b[1]&0xE0 != 0xE0 {
+		return f, false
+	}
+	versionBits := (b[1] >> 3) & 3
+	layerBits := (b[1] >> 1) & 3
+	bitrateIndex := b[2] >> 4
+	sampleRateIndex := (b[2] >> 2) & 3
+	if versionBits == 1 || layerBits == 0 || bitrateIndex == 0 || bitrateIndex == 15 || sampleRateIndex == 3 {
+		return f, false // reserved, free format, or bad values
+	}
+	f = mp3Frame{
+		mpeg1:      versionBits == 3,
+		layer:      int(4 - layerBits),
+		sampleRate: mp3SampleRates[versionBits][sampleRateIndex],
+		padding:    int(b[2]>>1) & 1,
+		mono:       b[3]>>6 == 3,
+	}
+	f.bitrate = mp3Bitrates[f.mpeg1][f.layer][bitrateIndex] * 1000
+	return f, true
+}
+
+// samples is the number of audio samples in each channel of the frame
+func (f mp3Frame) samples() int {
+	switch {
+	case f.layer == 1:
+		return 384
+	case f.layer == 3 && !f.mpeg1:
+		return 576
+	}
+	return 1152
+}
+
+// size is the number of bytes in the frame, including the header
+func (f mp3Frame) size() int {
+	if f.layer == 1 {
+		return (12*f.bitrate/f.sampleRate + f.padding) * 4
+	}
+	return f.samples()/8*f.bitrate/f.sampleRate + f.padding
+}
+
+// vbrFrames reads the frame count from the Xing/Info or VBRI header of the frame data, if it has one
+func (f mp3Frame) vbrFrames(data []byte) (int, bool) {
+	xingOffset := 4 + 32
+	switch {
+	case f.mpeg1 && f.mono, !f.mpeg1 && !f.mono:
+		xingOffset = 4 + 17
+	case !f.mpeg1 && f.mono:
+		xingOffset = 4 + 9
+	}
+	if len(data) >= xingOffset+12 {
+		switch string(data[xingOffset : xingOffset+4]) {
+		case "Xing", "Info":
+			flags := binary.BigEndian.Uint32(data[xingOffset+4:])
+			if flags&1 != 0 {
+				return int(binary.BigEndian.Uint32(data[xingOffset+8:])), true
+			}
+		}
+	}
+	const vbriOffset = 4 + 32
+	if len(data) >= vbriOffset+18 && string(data[vbriOffset:vbriOffset+4]) == "VBRI" {
+		return int(binary.BigEndian.Uint32(data[vbriOffset+14:])), true
+	}
+	return 0, false
+}
+
+// mp3Duration computes the length of the mp3 audio.
+// The frame count is read from the VBR header of the first frame.
+// If there is no header and the first frames have the same bitrate, the duration is estimated from the size of the audio without reading the rest of the file.
+// Otherwise, the frames are counted.
+func mp3Duration(r io.ReadSeeker) (time.Duration, error) {
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, fmt.Errorf("seeking to end of file: %v", err)
+	}
+	audio, err := mp3AudioSections(r, size)
+	if err != nil {
+		audio = nil // the audio size is not known, so the frames are counted
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("seeking to start of file: %v", err)
+	}
+	br := bufio.NewReader(r)
+	if err := skipID3v2(br); err != nil {
+		return 0, err
+	}
+	f, skipped, err := findMP3Frame(br)
+	if err != nil {
+		return 0, err
+	}
+	data, _ := br.Peek(f.size())
+	if frames, ok := f.vbrFrames(data); ok {
+		return samplesDuration(int64(frames)*int64(f.samples()), int64(f.sampleRate)), nil
+	}
+	sampleRate, bitrate := f.sampleRate, f.bitrate
+	constantBitrate := len(audio) == 1
+	var samples int64
+	for frames, ok := 0, true; ok; f, ok = parseMP3Frame(data) {
+		if f.bitrate != bitrate {
+			constantBitrate = false
+		}
+		if constantBitrate && frames == mp3ConstantBitrateFrames {
+			audioBits := (audio[0].end - audio[0].start - int64(skipped)) * 8
+			return samplesDuration(audioBits, int64(bitrate)), nil
+		}
+		samples += int64(f.samples())
+		frames++
+		if _, err := br.Discard(f.size()); err != nil {
+			break // partial last frame
+		}
+		data, _ = br.Peek(4)
+	}
+	return samplesDuration(samples, int64(sampleRate)), nil
+}
+
+// skipID3v2 discards the ID3v2 tag at the start of the reader, if there is one
+func skipID3v2(br *bufio.Reader) error {
+	h, err := br.Peek(10)
+	if err != nil || string(h[:3]) != "ID3" {
+		return nil
+	}
+	size := 10 + (int(h[6]&0x7F)<<21 | int(h[7]&0x7F)<<14 | int(h[8]&0x7F)<<7 | int(h[9]&0x7F)) // syncsafe integer
+	if h[5]&0x10 != 0 {                                                                         // footer
+		size += 10
+	}
+	if _, err := br.Discard(size); err != nil {
+		return fmt.Errorf("skipping ID3v2 tag: %v", err)
+	}
+	return nil
+}
+
+// findMP3Frame discards bytes until the start of the first frame, returning the number of bytes that were discarded
+func findMP3Frame(br *bufio.Reader) (mp3Frame, int, error) {
+	for i := 0; i < mp3FrameSearchLimit; i++ {
+		b, err := br.Peek(4)
+		if err != nil {
+			break
+		}
+		if f, ok := parseMP3Frame(b); ok {
+			return f, i, nil
+		}
+		br.Discard(1)
+	}
+	return mp3Frame{}, 0, fmt.Errorf("no mp3 frames found")
+}
+
+// mp4Duration computes the length of the mp4 audio from the movie or media header atom
+func mp4Duration(r io.ReadSeeker) (time.Duration, error) {
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, fmt.Errorf("seeking to end of file: %v", err)
+	}
+	for _, path := range [][]string{
+		{"moov", "mvhd"},
+		{"moov", "trak", "mdia", "mdhd"},
+	} {
+		start, _, err := findMP4Atom(r, 0, end, path...)
+		if err != nil {
+			continue
+		}
+		if d, err := readMP4HeaderDuration(r, start); err == nil && d > 0 {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("no mp4 duration found")
+}
+
+// findMP4Atom finds the atom at the path of atom names inside the section of the reader, returning where its contents start and end.
+func findMP4Atom(r io.ReadSeeker, start, end int64, names ...string) (int64, int64, error) {
+	var h [16]byte
+	for pos := start; pos+8 <= end; {
+		if _, err := r.Seek(pos, io.SeekStart); err != nil {
+			return 0, 0, err
+		}
+		if _, err := io.ReadFull(r, h[:8]); err != nil {
+			return 0, 0, err
+		}
+		size, headerSize := int64(binary.BigEndian.Uint32(h[:4])), int64(8)
+		switch size {
+		case 0: // atom extends to end
+			size = end - pos
+		case 1: // 64-bit size
+			if _, err := io.ReadFull(r, h[8:16]); err != nil {
+				return 0, 0, err
+			}
+			size, headerSize = int64(binary.BigEndian.Uint64(h[8:16])), 16
+		}
+		if size < headerSize || pos+size > end {
+			return 0, 0, fmt.Errorf("invalid size of %q atom: %v", h[4:8], size)
+		}
+		if string(h[4:8]) == names[0] {
+			if len(names) == 1 {
+				return pos + headerSize, pos + size, nil
+			}
+			return findMP4Atom(r, pos+headerSize, pos+size, names[1:]...)
+		}
+		pos += size
+	}
+	return 0, 0, fmt.Errorf("%q atom not found", names[0])
+}
+
+// readMP4HeaderDuration reads the duration from the contents of a mvhd or mdhd atom
+func readMP4HeaderDuration(r io.ReadSeeker, start int64) (time.Duration, error) {
+	if _, err := r.Seek(start, io.SeekStart); err != nil {
+		return 0, err
+	}
+	var h [32]byte
+	if _, err := io.ReadFull(r, h[:1]); err != nil {
+		return 0, err
+	}
+	version := h[0]
+	var timescale, duration uint64
+	switch version {
+	case 0: // flags(3), created(4), modified(4), timescale(4), duration(4)
+		if _, err := io.ReadFull(r, h[:19]); err != nil {
+			return 0, err
+		}
+		timescale = uint64(binary.BigEndian.Uint32(h[11:15]))
+		duration = uint64(binary.BigEndian.Uint32(h[15:19]))
+	case 1: // flags(3), created(8), modified(8), timescale(4), duration(8)
+		if _, err := io.ReadFull(r, h[:31]); err != nil {
+			return 0, err
+		}
+		timescale = uint64(binary.BigEndian.Uint32(h[19:23]))
+		duration = binary.BigEndian.Uint64(h[23:31])
+	default:
+		return 0, fmt.Errorf("unknown header version: %v", version)
+	}
+	if timescale == 0 {
+		return 0, fmt.Errorf("header has no timescale")
+	}
+	return samplesDuration(int64(duration), int64(timescale)), nil
+}
+
+// samplesDuration converts a number of samples at a rate to a duration without overflowing
+func samplesDuration(samples, rate int64) time.Duration {
+	if rate <= 0 {
+		return 0
+	}
+	seconds, remainder := samples/rate, samples%rate
+	return time.Duration(seconds)*time.Second + time.Duration(remainder)*time.Second/time.Duration(rate)
+}
+
+// formatDuration displays the duration as minutes and seconds, or hours, minutes, and seconds if it is long.
+// Zero durations are not known.
+func formatDuration(d time.Duration) string {
+	if d <= 0 {
+		return "?"
+	}
+	s := int64(d.Round(time.Second) / time.Second)
+	if s >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+	}
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+	"time"
+)
+
+// mockMP3Frames creates mp3 data with the frames after an ID3v2 tag.
+// The frames are MPEG-1 Layer III, 128kbps, 44100Hz, stereo, so they are each 417 bytes.
+func mockMP3Frames(n int, firstFrame func(frame []byte)) []byte {
+	var b bytes.Buffer
+	b.WriteString("ID3\x03\x00\x00\x00\x00\x00\x05hello")
+	for i := 0; i < n; i++ {
+		frame := make([]byte, 417)
+		copy(frame, []byte{0xFF, 0xFB, 0x90, 0x00})
+		if i == 0 && firstFrame != nil {
+			firstFrame(frame)
+		}
+		b.Write(frame)
+	}
+	b.WriteString("TAG") // ID3v1 tag at end of file, truncated
+	return b.Bytes()
+}
+
+// mockMP3Bitrates creates mp3 data with a MPEG-1 Layer III, 44100Hz, stereo frame for each bitrate index after an ID3v2 tag
+func mockMP3Bitrates(bitrateIndexes ...byte) []byte {
+	var b bytes.Buffer
+	b.WriteString("ID3\x03\x00\x00\x00\x00\x00\x00")
+	for _, i := range bitrateIndexes {
+		h := []byte{0xFF, 0xFB, i << 4, 0x00}
+		f, _ := parseMP3Frame(h)
+		frame := make([]byte, f.size())
+		copy(frame, h)
+		b.Write(frame)
+	}
+	return b.Bytes()
+}
+
+// countingReadSeeker counts the bytes that are read
+type countingReadSeeker struct {
+	io.ReadSeeker
+	n int
+}
+
+func (r *countingReadSeeker) Read(p []byte) (int, error) {
+	n, err := r.ReadSeeker.Read(p)
+	r.n += n
+	return n, err
+}
+
+// mockMP4Atom creates an mp4 atom with the contents
+func mockMP4Atom(name string, contents ...[]byte) []byte {
+	b := bytes.Join(contents, nil)
+	h := make([]byte, 8, 8+len(b))
+	binary.BigEndian.PutUint32(h, uint32(8+len(b)))
+	copy(h[4:], name)
+	return append(h, b...)
+}
+
+// mockMP4Header creates the contents of a version 0 mvhd or mdhd atom
+func mockMP4Header(timescale, duration uint32) []byte {
+	b := make([]byte, 20)
+	binary.BigEndian.PutUint32(b[12:], timescale)
+	binary.BigEndian.PutUint32(b[16:], duration)
+	return b
+}
+
+func TestParseMP3Frame(t *testing.T) {
+	tests := []struct {
+		name     string
+		b        []byte
+		wantOk   bool
+		wantSize int
+	}{
+		{"too short", []byte{0xFF, 0xFB, 0x90}, false, 0},
+		{"no sync", []byte{0xFF, 0x0B, 0x90, 0x00}, false, 0},
+		{"reserved version", []byte{0xFF, 0xEB, 0x90, 0x00}, false, 0},
+		{"reserved layer", []byte{0xFF, 0xF9, 0x90, 0x00}, false, 0},
+		{"free bitrate", []byte{0xFF, 0xFB, 0x00, 0x00}, false, 0},
+		{"bad bitrate", []byte{0xFF, 0xFB, 0xF0, 0x00}, false, 0},
+		{"reserved sample rate", []byte{0xFF, 0xFB, 0x9C, 0x00}, false, 0},
+		{"mpeg1 layer3", []byte{0xFF, 0xFB, 0x90, 0x00}, true, 417},
+		{"mpeg1 layer3 padded", []byte{0xFF, 0xFB, 0x92, 0x00}, true, 418},
+		{"mpeg2 layer3", []byte{0xFF, 0xF3, 0x90, 0x00}, true, 261}, // 80kbps, 22050Hz
+		{"mpeg1 layer1", []byte{0xFF, 0xFF, 0x90, 0x00}, true, 312}, // 288kbps, 44100Hz
+		{"mpeg1 layer2", []byte{0xFF, 0xFD, 0x90, 0x00}, true, 522}, // 160kbps, 44100Hz
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f, ok := parseMP3Frame(test.b)
+			switch {
+			case test.wantOk != ok:
+				t.Errorf("wanted valid frame: %v, got %v", test.wantOk, ok)
+			case ok && test.wantSize != f.size():
+				t.Errorf("frame sizes not equal: wanted %v, got %v", test.wantSize, f.size())
+			}
+		})
+	}
+}
+
+func TestMP3Duration(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		want    time.Duration
+		wantErr bool
+	}{
+		{
+			name:    "empty",
+			wantErr: true,
+		},
+		{
+			name:    "no frames",
+			data:    []byte("ID3\x03\x00\x00\x00\x00\x00\x00not an mp3"),
+			wantErr: true,
+		},
+		{
+			name: "xing header",
+			data: emptyMP3,
+			want: emptyMp3Duration,
+		},
+		{
+			name: "counted frames",
+			data: mockMP3Frames(5, nil),
+			want: 5760 * time.Second / 44100,
+		},
+		{
+			name: "constant bitrate estimated from size",
+			data: mockMP3Frames(10, nil),
+			want: (10*417 + 3) * 8 * time.Second / 128000, // the truncated ID3v1 tag is counted as audio
+		},
+		{
+			name: "variable bitrate counted",
+			data: mockMP3Bitrates(9, 9, 9, 10, 9, 9, 9, 9, 9, 9),
+			want: 11520 * time.Second / 44100,
+		},
+		{
+			name: "info header",
+			data: mockMP3Frames(1, func(frame []byte) {
+				copy(frame[36:], "Info\x00\x00\x00\x01\x00\x00\x27\x10") // 10000 frames
+			}),
+			want: 11520000 * time.Second / 44100,
+		},
+		{
+			name: "vbri header",
+			data: mockMP3Frames(1, func(frame []byte) {
+				copy(frame[36:], "VBRI\x00\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x03\xe8") // 1000 frames
+			}),
+			want: 1152000 * time.Second / 44100,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := mp3Duration(bytes.NewReader(test.data))
+			switch {
+			case test.wantErr:
+				if err == nil {
+					t.Error("wanted error")
+				}
+			case err != nil:
+				t.Errorf("unwanted error: %v", err)
+			case test.want != got:
+				t.Errorf("durations not equal: wanted %v, got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestMP3DurationConstantBitrateNotReadInFull(t *testing.T) {
+	data := mockMP3Frames(1000, nil)
+	r := countingReadSeeker{ReadSeeker: bytes.NewReader(data)}
+	got, err := mp3Duration(&r)
+	want := (1000*417 + 3) * 8 * time.Second / 128000
+	switch {
+	case err != nil:
+		t.Fatalf("unwanted error: %v", err)
+	case want != got:
+		t.Errorf("durations not equal: wanted %v, got %v", want, got)
+	case r.n > len(data)/10:
+		t.Errorf("wanted only the start of the file to be read, read %v of %v bytes", r.n, len(data))
+	}
+}
+
+func TestMP4Duration(t *testing.T) {
+	ftyp := mockMP4Atom("ftyp", []byte("M4A \x00\x00\x00\x00"))
+	version1Header := make([]byte, 32)
+	version1Header[0] = 1
+	binary.BigEndian.PutUint32(version1Header[20:], 1000)
+	binary.BigEndian.PutUint64(version1Header[24:], 90500)
+	tests := []struct {
+		name    string
+		data    []byte
+		want    time.Duration
+		wantErr bool
+	}{
+		{
+			name:    "no moov",
+			data:    ftyp,
+			wantErr: true,
+		},
+		{
+			name:    "bad atom size",
+			data:    append(ftyp, 0, 0, 0, 4, 'm', 'o', 'o', 'v'),
+			wantErr: true,
+		},
+		{
+			name: "mvhd",
+			data: bytes.Join([][]byte{
+				ftyp,
+				mockMP4Atom("moov",
+					mockMP4Atom("mvhd", mockMP4Header(600, 1500)),
+				),
+			}, nil),
+			want: 2500 * time.Millisecond,
+		},
+		{
+			name: "mvhd version 1",
+			data: bytes.Join([][]byte{
+				ftyp,
+				mockMP4Atom("moov",
+					mockMP4Atom("mvhd", version1Header),
+				),
+			}, nil),
+			want: 90500 * time.Millisecond,
+		},
+		{
+			name: "mdhd",
+			data: bytes.Join([][]byte{
+				ftyp,
+				mockMP4Atom("free", []byte("padding")),
+				mockMP4Atom("moov",
+					mockMP4Atom("mvhd", mockMP4Header(600, 0)),
+					mockMP4Atom("trak",
+						mockMP4Atom("tkhd", make([]byte, 12)),
+						mockMP4Atom("mdia",
+							mockMP4Atom("mdhd", mockMP4Header(44100, 441000)),
+						),
+					),
+				),
+			}, nil),
+			want: 10 * time.Second,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := mp4Duration(bytes.NewReader(test.data))
+			switch {
+			case test.wantErr:
+				if err == nil {
+					t.Error("wanted error")
+				}
+			case err != nil:
+				t.Errorf("unwanted error: %v", err)
+			case test.want != got:
+				t.Errorf("durations not equal: wanted %v, got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "?"},
+		{-time.Second, "?"},
+		{400 * time.Millisecond, "0:00"},
+		{time.Second, "0:01"},
+		{59*time.Second + 500*time.Millisecond, "1:00"},
+		{185 * time.Second, "3:05"},
+		{59*time.Minute + 59*time.Second, "59:59"},
+		{time.Hour + 2*time.Minute + 3*time.Second, "1:02:03"},
+	}
+	for _, test := range tests {
+		t.Run(test.d.String(), func(t *testing.T) {
+			if got := formatDuration(test.d); test.want != got {
+				t.Errorf("wanted %q, got %q", test.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// defaultFuzzyThreshold is the minimum score of songs that match fuzzy filters, out of 100
+const defaultFuzzyThreshold = 70
+
+// fuzzyFilter limits the songs to the songs with artists, albums, or titles that are similar to the words, ranked by how well they match.
+// Misspelled words match, such as beatels, as do the starts of words, such as zep.
+func (p *playlist) fuzzyFilter(command string) {
+	words := fuzzyWords(command)
+	if len(words) == 0 {
+		p.fail("Error (fuzzy filter): missing words\n")
+		return
+	}
+	type rankedSong struct {
+		song
+		score int
+	}
+	var ranked []rankedSong
+	for _, s := range p.songs {
+		if score := fuzzyScore(s, words); score > 0 && score >= p.fuzzyThreshold {
+			ranked = append(ranked, rankedSong{s, score})
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].score > ranked[j].score // ties are in library order
+	})
+	p.selection = p.selection[:0]
+	p.scores = make([]int, len(ranked))
+	for i, r := range ranked {
+		p.selection = append(p.selection, r.song)
+		p.scores[i] = r.score
+	}
+	p.printSongFilter("")
+}
+
+// setFuzzyThreshold sets the minimum score of songs that match fuzzy filters: fuzzy [score], or displays it if no score is given
+func (p *playlist) setFuzzyThreshold(command string) {
+	command = strings.TrimSpace(command)
+	if len(command) == 0 {
+		fmt.Fprintf(p.w, "fuzzy filter threshold: %v\n", p.fuzzyThreshold)
+		return
+	}
+	threshold, err := strconv.Atoi(command)
+	if err != nil || threshold < 0 || threshold > 100 {
+		p.fail("Error (fuzzy filter threshold): wanted a score from 0 to 100, got %q\n", command)
+		return
+	}
+	p.fuzzyThreshold = threshold
+}
+
+// fuzzyWords splits the text into lowercase words of letters and digits
+func fuzzyWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// fuzzyScore rates how well the words match the artist, album, and title of the song, from 0 to 100.
+// The score is the average similarity of each word to the most similar word of the song.
+func fuzzyScore(s song, words []string) int {
+	songWords := fuzzyWords(s.artist + " " + s.album + " " + s.title)
+	if len(songWords) == 0 {
+		return 0
+	}
+	total := 0.0
+	for _, w := range words {
+		best := 0.0
+		for _, sw := range songWords {
+			if sim := wordSimilarity(w, sw); best < sim {
+				best = sim
+			}
+		}
+		total += best
+	}
+	return int(total/float64(len(words))*100 + 0.5)
+}
+
+// wordSimilarity rates how similar the word is to the song word, from 0 to 1.
+// Words of at least three letters that start song words are as similar as the same words.
+func wordSimilarity(word, songWord string) float64 {
+	if len(word) >= 3 && strings.HasPrefix(songWord, word) {
+		return 1
+	}
+	a, b := []rune(word), []rune(songWord)
+	maxLen := len(a)
+	if maxLen < len(b) {
+		maxLen = len(b)
+	}
+	return 1 - float64(editDistance(a, b))/float64(maxLen)
+}
+
+// editDistance counts the insertions, deletions, substitutions, and swaps of adjacent letters needed to change a into b
+func editDistance(a, b []rune) int {
+	// rows of the distances between the prefixes of a and b, two rows back, the previous row, and the current row
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d := prev[j-1] + cost // substitution
+			if prev[j]+1 < d {
+				d = prev[j] + 1 // deletion
+			}
+			if curr[j-1]+1 < d {
+				d = curr[j-1] + 1 // insertion
+			}
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] && prev2[j-2]+1 < d {
+				d = prev2[j-2] + 1 // swap
+			}
+			curr[j] = d
+		}
+		prev2, prev, curr = prev, curr, prev2
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"", "abc", 3},
+		{"beatles", "beatles", 0},
+		{"beatels", "beatles", 1},
+		{"beetles", "beatles", 1},
+		{"beatle", "beatles", 1},
+		{"kitten", "sitting", 3},
+		{"ca", "abc", 3},
+		{"björk", "bjork", 1},
+	}
+	for _, test := range tests {
+		if got := editDistance([]rune(test.a), []rune(test.b)); test.want != got {
+			t.Errorf("edit distance from %q to %q not equal: wanted %v, got %v", test.a, test.b, test.want, got)
+		}
+	}
+}
+
+func TestFuzzyWords(t *testing.T) {
+	if want, got := "[ac dc back in black 1980]", fmt.Sprint(fuzzyWords("AC/DC - Back in Black (1980)")); want != got {
+		t.Errorf("words not equal: wanted %v, got %v", want, got)
+	}
+}
+
+func TestFuzzyScore(t *testing.T) {
+	s := song{artist: "The Beatles", album: "Abbey Road", title: "Come Together"}
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"beatles", 100},
+		{"Beatles abbey road", 100},
+		{"beatels", 86},
+		{"beatels abey", 83},
+		{"togeth", 100},
+		{"to", 33},
+		{"zeppelin", 25},
+		{"beatles zeppelin", 63},
+	}
+	for _, test := range tests {
+		if got := fuzzyScore(s, fuzzyWords(test.query)); test.want != got {
+			t.Errorf("score of %q not equal: wanted %v, got %v", test.query, test.want, got)
+		}
+	}
+	if got := fuzzyScore(song{path: "a.mp3"}, []string{"a"}); got != 0 {
+		t.Errorf("wanted song without tags to not match, got score %v", got)
+	}
+}
+
+func TestPlaylistFuzzyFilter(t *testing.T) {
+	songs := []song{
+		{artist: "Beck", album: "Guero", title: "E-Pro"},
+		{artist: "The Beatles", album: "Abbey Road", title: "Come Together"},
+		{artist: "The Beatles", album: "Abbey Road", title: "Something"},
+		{artist: "Led Zeppelin", album: "IV", title: "Black Dog"},
+		{artist: "Beatless", album: "Covers", title: "Help"},
+	}
+	tests := []struct {
+		name      string
+		command   string
+		threshold int
+		want      []song
+		wantErr   bool
+	}{
+		{"ranked", "beatels", 70, []song{songs[1], songs[2], songs[4]}, false},
+		{"words", "beatels something", 70, []song{songs[2]}, false},
+		{"start of word", "zep", 70, []song{songs[3]}, false},
+		{"threshold", "beatels", 80, []song{songs[1], songs[2]}, false},
+		{"no matches", "xyzzy", 70, nil, false},
+		{"no words", " - ", 70, nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var w bytes.Buffer
+			p := playlist{
+				songs:          songs,
+				selection:      []song{songs[0]},
+				w:              &w,
+				fuzzyThreshold: test.threshold,
+			}
+			p.fuzzyFilter(test.command)
+			switch {
+			case test.wantErr:
+				if !strings.HasPrefix(w.String(), "Error") {
+					t.Errorf("wanted error, got %q", w.String())
+				}
+			case fmt.Sprint(test.want) != fmt.Sprint(p.selection):
+				t.Errorf("selections not equal: \n wanted: %v \n got:    %v", test.want, p.selection)
+			case len(p.scores) != len(p.selection):
+				t.Errorf("wanted a score for each selected song, got %v", p.scores)
+			case !strings.HasPrefix(w.String(), "Score"):
+				t.Errorf("wanted selection to be printed with scores, got %q", w.String())
+			}
+		})
+	}
+	t.Run("filter clears scores", func(t *testing.T) {
+		var w bytes.Buffer
+		p := playlist{
+			songs:          songs,
+			w:              &w,
+			fuzzyThreshold: defaultFuzzyThreshold,
+		}
+		p.fuzzyFilter("beatles")
+		p.filter("beck")
+		if p.scores != nil {
+			t.Errorf("wanted scores to be cleared, got %v", p.scores)
+		}
+	})
+}
+
+func TestPlaylistSetFuzzyThreshold(t *testing.T) {
+	tests := []struct {
+		command string
+		want    int
+		wantOut string
+	}{
+		{"", 70, "fuzzy filter threshold: 70\n"},
+		{"85", 85, ""},
+		{" 0 ", 0, ""},
+		{"101", 70, "Error (fuzzy filter threshold): wanted a score from 0 to 100, got \"101\"\n"},
+		{"high", 70, "Error (fuzzy filter threshold): wanted a score from 0 to 100, got \"high\"\n"},
+	}
+	for _, test := range tests {
+		var w bytes.Buffer
+		p := playlist{
+			w:              &w,
+			fuzzyThreshold: defaultFuzzyThreshold,
+		}
+		p.setFuzzyThreshold(test.command)
+		if test.want != p.fuzzyThreshold {
+			t.Errorf("threshold after %q not equal: wanted %v, got %v", test.command, test.want, p.fuzzyThreshold)
+		}
+		if test.wantOut != w.String() {
+			t.Errorf("output of %q not equal: wanted %q, got %q", test.command, test.wantOut, w.String())
+		}
+	}
+}
@@ -2,7 +2,4 @@ module github.com/jacobpatterson1549/m3u-playlist-create
 
 go 1.18
 
-require (
-	github.com/dhowden/tag v0.0.0-20220530110423-77907a30b7f1
-	golang.org/x/sys v0.0.0-20220712014510-0a85c31ab51e
-)
+require github.com/dhowden/tag v0.0.0-20220530110423-77907a30b7f1
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+)
+
+// historyLimit is the maximum number of changes to the playlist that can be undone
+const historyLimit = 100
+
+type (
+	// playlistHistory stores previous states of the playlist tracks so changes can be undone and redone
+	playlistHistory struct {
+		undo, redo []playlistState
+	}
+	// playlistState is a copy of the playlist tracks from before or after a change
+	playlistState struct {
+		change string
+		tracks []m3uTrack
+		loaded bool   // the change loaded a playlist file, so the path is also restored
+		path   string // the playlist file that was last loaded or written before or after the change
+	}
+)
+
+// record saves the tracks before they are changed so the change can be undone.
+// Changes that were undone can no longer be redone.
+func (p *playlist) record(change string) {
+	s := playlistState{
+		change: change,
+		tracks: copyTracks(p.tracks),
+	}
+	p.history.undo = append(p.history.undo, s)
+	if len(p.history.undo) > historyLimit {
+		p.history.undo = p.history.undo[len(p.history.undo)-historyLimit:]
+	}
+	p.history.redo = nil
+	p.dirty = true
+}
+
+// recordLoad saves the tracks and the path of the playlist file before a playlist is loaded so the load can be undone
+func (p *playlist) recordLoad(change string) {
+	p.record(change)
+	s := &p.history.undo[len(p.history.undo)-1]
+	s.loaded = true
+	s.path = p.path
+}
+
+// undo reverts the last change to the playlist tracks
+func (p *playlist) undo(_ string) {
+	if len(p.history.undo) == 0 {
+		p.fail("Error (undo): no changes to undo\n")
+		return
+	}
+	p.history.undo, p.history.redo = swapState(p.history.undo, p.history.redo, &p.tracks, &p.path)
+	p.dirty = true
+}
+
+// redo reapplies the last change to the playlist tracks that was undone
+func (p *playlist) redo(_ string) {
+	if len(p.history.redo) == 0 {
+		p.fail("Error (redo): no changes to redo\n")
+		return
+	}
+	p.history.redo, p.history.undo = swapState(p.history.redo, p.history.undo, &p.tracks, &p.path)
+	p.dirty = true
+}
+
+// swapState moves the last state from src to dest, replacing the tracks with those of the state.
+// The current tracks are stored in the state that is moved.
+// The path of the playlist file is also swapped if the state is of a load.
+func swapState(src, dest []playlistState, tracks *[]m3uTrack, path *string) ([]playlistState, []playlistState) {
+	s := src[len(src)-1]
+	src = src[:len(src)-1]
+	*tracks, s.tracks = s.tracks, *tracks
+	if s.loaded {
+		*path, s.path = s.path, *path
+	}
+	dest = append(dest, s)
+	return src, dest
+}
+
+// printHistory lists the changes that can be undone and redone, most recent first
+func (p *playlist) printHistory(_ string) {
+	if len(p.history.undo) == 0 && len(p.history.redo) == 0 {
+		fmt.Fprintf(p.w, "no changes\n")
+		return
+	}
+	for i := 0; i < len(p.history.redo); i++ {
+		fmt.Fprintf(p.w, "  redo: %v\n", p.history.redo[i].change)
+	}
+	for i := len(p.history.undo) - 1; i >= 0; i-- {
+		fmt.Fprintf(p.w, "  undo: %v\n", p.history.undo[i].change)
+	}
+	if p.dirty {
+		fmt.Fprintf(p.w, "the playlist has unsaved changes\n")
+	}
+}
+
+// confirmDiscard checks that unsaved changes can be discarded for the action
+func (p *playlist) confirmDiscard(action string) bool {
+	if !p.dirty || p.confirm == nil {
+		return true
+	}
+	return p.confirm(fmt.Sprintf("The playlist has unsaved changes. %v anyway?", action))
+}
+
+func copyTracks(tracks []m3uTrack) []m3uTrack {
+	if tracks == nil {
+		return nil
+	}
+	c := make([]m3uTrack, len(tracks))
+	copy(c, tracks)
+	return c
+}
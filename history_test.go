@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestPlaylistUndoRedo(t *testing.T) {
+	var w bytes.Buffer
+	p := playlist{
+		selection: []song{{artist: "a", title: "1"}, {artist: "b", title: "2"}},
+		w:         &w,
+	}
+	displays := func() string {
+		var d []string
+		for _, t := range p.tracks {
+			d = append(d, t.display)
+		}
+		return strings.Join(d, ",")
+	}
+	steps := []struct {
+		name    string
+		f       func(string)
+		command string
+		want    string
+		wantErr bool
+	}{
+		{"undo nothing", p.undo, "", "", true},
+		{"redo nothing", p.redo, "", "", true},
+		{"add 1", p.addTrack, "1", "a - 1", false},
+		{"add 2", p.addTrack, "2", "a - 1,b - 2", false},
+		{"move", p.moveTrack, "2 1", "b - 2,a - 1", false},
+		{"rename", p.renameTrack, "1 two", "two,a - 1", false},
+		{"remove", p.removeTrack, "2", "two", false},
+		{"undo remove", p.undo, "", "two,a - 1", false},
+		{"undo rename", p.undo, "", "b - 2,a - 1", false},
+		{"redo rename", p.redo, "", "two,a - 1", false},
+		{"clear", p.clearTracks, "", "", false},
+		{"redo cleared by change", p.redo, "", "", true},
+		{"undo clear", p.undo, "", "two,a - 1", false},
+		{"undo rename again", p.undo, "", "b - 2,a - 1", false},
+		{"undo move", p.undo, "", "a - 1,b - 2", false},
+		{"undo add 2", p.undo, "", "a - 1", false},
+		{"undo add 1", p.undo, "", "", false},
+		{"undo too far", p.undo, "", "", true},
+		{"redo add 1", p.redo, "", "a - 1", false},
+	}
+	for _, step := range steps {
+		w.Reset()
+		step.f(step.command)
+		if want, got := step.want, displays(); want != got {
+			t.Errorf("%v: tracks not equal: wanted %q, got %q", step.name, want, got)
+		}
+		if want, got := step.wantErr, w.Len() != 0; want != got {
+			t.Errorf("%v: wanted logged error: %v, got %q", step.name, want, w.String())
+		}
+	}
+	if !p.dirty {
+		t.Error("wanted playlist to have unsaved changes")
+	}
+}
+
+func TestPlaylistUndoRedoLoad(t *testing.T) {
+	songs := []song{{path: "x.mp3", title: "x"}, {path: "y.mp3", title: "y"}}
+	p := playlist{
+		songs:  songs,
+		tracks: []m3uTrack{{song: songs[0], display: "x"}},
+		path:   "a.m3u",
+		fsys: MockPlaylistFS{
+			FS: fstest.MapFS{
+				"b.m3u": &fstest.MapFile{Data: []byte("y.mp3\n")},
+			},
+		},
+		w: io.Discard,
+	}
+	p.load("b.m3u")
+	steps := []struct {
+		name      string
+		f         func(string)
+		wantPath  string
+		wantTrack string
+	}{
+		{"undo load", p.undo, "a.m3u", "x.mp3"},
+		{"redo load", p.redo, "b.m3u", "y.mp3"},
+	}
+	for _, step := range steps {
+		step.f("")
+		switch {
+		case step.wantPath != p.path:
+			t.Errorf("%v: playlist paths not equal: wanted %q, got %q", step.name, step.wantPath, p.path)
+		case len(p.tracks) != 1 || step.wantTrack != p.tracks[0].path:
+			t.Errorf("%v: wanted track of %q, got %v", step.name, step.wantTrack, p.tracks)
+		}
+	}
+	t.Run("other changes keep path", func(t *testing.T) {
+		p.selection = songs
+		p.addTrack("1")
+		p.path = "c.m3u" // written
+		p.undo("")
+		if want, got := "c.m3u", p.path; want != got {
+			t.Errorf("playlist paths not equal: wanted %q, got %q", want, got)
+		}
+	})
+}
+
+func TestPlaylistRecordLimit(t *testing.T) {
+	var p playlist
+	for i := 0; i < historyLimit+5; i++ {
+		p.record(fmt.Sprint(i))
+	}
+	if want, got := historyLimit, len(p.history.undo); want != got {
+		t.Errorf("wanted history to be limited to %v changes, got %v", want, got)
+	}
+	if want, got := "5", p.history.undo[0].change; want != got {
+		t.Errorf("wanted oldest changes to be dropped, oldest change is %q, wanted %q", got, want)
+	}
+}
+
+func TestPlaylistPrintHistory(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		var w bytes.Buffer
+		p := playlist{w: &w}
+		p.printHistory("")
+		if want, got := "no changes\n", w.String(); want != got {
+			t.Errorf("wanted %q, got %q", want, got)
+		}
+	})
+	t.Run("changes", func(t *testing.T) {
+		var w bytes.Buffer
+		p := playlist{
+			w: &w,
+			history: playlistHistory{
+				undo: []playlistState{{change: "first"}, {change: "second"}},
+				redo: []playlistState{{change: "fourth"}, {change: "third"}},
+			},
+			dirty: true,
+		}
+		p.printHistory("")
+		want := "  redo: fourth\n" +
+			"  redo: third\n" +
+			"  undo: second\n" +
+			"  undo: first\n" +
+			"the playlist has unsaved changes\n"
+		if got := w.String(); want != got {
+			t.Errorf("history not equal: \n wanted: %q \n got:    %q", want, got)
+		}
+	})
+}
+
+func TestPlaylistConfirmDiscard(t *testing.T) {
+	tests := []struct {
+		name        string
+		dirty       bool
+		answer      bool
+		want        bool
+		wantConfirm bool
+	}{
+		{"saved", false, false, true, false},
+		{"unsaved, yes", true, true, true, true},
+		{"unsaved, no", true, false, false, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			confirmed := false
+			p := playlist{
+				dirty: test.dirty,
+				confirm: func(question string) bool {
+					confirmed = true
+					return test.answer
+				},
+			}
+			if want, got := test.want, p.confirmDiscard("Test"); want != got {
+				t.Errorf("wanted %v, got %v", want, got)
+			}
+			if want, got := test.wantConfirm, confirmed; want != got {
+				t.Errorf("wanted confirmation: %v, got %v", want, got)
+			}
+		})
+	}
+}
+
+func TestPlaylistDestructiveCommandsDeclined(t *testing.T) {
+	tracks := []m3uTrack{{display: "unsaved"}}
+	newPlaylist := func() playlist {
+		return playlist{
+			songs:  []song{{path: "a.mp3"}},
+			tracks: copyTracks(tracks),
+			dirty:  true,
+			fsys: MockPlaylistFS{
+				FS: fstest.MapFS{
+					"a.m3u": &fstest.MapFile{Data: []byte("a.mp3")},
+				},
+			},
+			confirm: func(question string) bool { return false },
+		}
+	}
+	want := playlist{
+		songs:  []song{{path: "a.mp3"}},
+		tracks: tracks,
+	}
+	t.Run("clear", func(t *testing.T) {
+		p := newPlaylist()
+		p.clearTracks("")
+		checkPlaylistsEqual(t, want, p)
+	})
+	t.Run("load", func(t *testing.T) {
+		p := newPlaylist()
+		p.load("a.m3u")
+		checkPlaylistsEqual(t, want, p)
+	})
+}
+
+func TestPlaylistLoadSaved(t *testing.T) {
+	var w bytes.Buffer
+	p := playlist{
+		songs: []song{{path: "a.mp3"}},
+		dirty: true,
+		fsys: MockPlaylistFS{
+			FS: fstest.MapFS{
+				"a.m3u": &fstest.MapFile{Data: []byte("a.mp3")},
+			},
+		},
+		confirm: func(question string) bool { return true },
+		w:       &w,
+	}
+	p.load("a.m3u")
+	switch {
+	case w.Len() != 0:
+		t.Errorf("unwanted error: %v", w.String())
+	case p.dirty:
+		t.Error("wanted loaded playlist to not have unsaved changes")
+	case len(p.history.undo) != 1:
+		t.Error("wanted load to be undoable")
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// libraryRoot is a folder songs are read from
+type libraryRoot struct {
+	name string // the folder as it was given, which labels the songs in it
+	dir  string // the slash-separated path of the folder relative to the working directory, empty for the working directory
+	abs  string // the absolute path of the folder, empty if the file system is not a folder
+	wd   string // the working directory with symbolic links resolved
+	fsys fs.FS
+}
+
+// newLibraryRoots opens the folders relative to the working directory.
+// Folders cannot be inside other folders because their songs would be read twice.
+func newLibraryRoots(names []string, wd string) ([]libraryRoot, error) {
+	roots := make([]libraryRoot, 0, len(names))
+	folders := make([]string, 0, len(names))
+	for _, name := range names {
+		r, err := newLibraryRoot(name, wd)
+		if err != nil {
+			return nil, err
+		}
+		folder := filepath.Join(wd, filepath.FromSlash(r.dir))
+		if isAbsolutePath(r.dir) {
+			folder = filepath.FromSlash(r.dir)
+		}
+		for i, f := range folders {
+			if folderContains(f, folder) || folderContains(folder, f) {
+				return nil, fmt.Errorf("library roots %q and %q overlap", roots[i].name, r.name)
+			}
+		}
+		roots = append(roots, r)
+		folders = append(folders, folder)
+	}
+	return roots, nil
+}
+
+// newLibraryRoot opens the folder relative to the working directory
+func newLibraryRoot(name, wd string) (libraryRoot, error) {
+	abs := name
+	if !filepath.IsAbs(abs) {
+		abs = filepath.Join(wd, name)
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return libraryRoot{}, fmt.Errorf("opening library root: %v", err)
+	}
+	if !info.IsDir() {
+		return libraryRoot{}, fmt.Errorf("library root %q is not a folder", name)
+	}
+	dir := filepath.ToSlash(abs)
+	if rel, err := filepath.Rel(wd, abs); err == nil {
+		dir = filepath.ToSlash(rel) // absolute if the folder is on another volume
+	}
+	if dir == "." {
+		dir = ""
+	}
+	realWD, err := filepath.EvalSymlinks(wd)
+	if err != nil {
+		realWD = wd
+	}
+	r := libraryRoot{
+		name: name,
+		dir:  dir,
+		abs:  abs,
+		wd:   realWD,
+		fsys: os.DirFS(abs),
+	}
+	return r, nil
+}
+
+// songPath is the path of the file in the root relative to the working directory
+func (r libraryRoot) songPath(name string) string {
+	if len(r.dir) == 0 {
+		return name
+	}
+	return path.Join(r.dir, name)
+}
+
+// canonicalPath is the path of the file in the root with symbolic links resolved, relative to the working directory.
+// The path is not resolved if the root is not a folder.
+func (r libraryRoot) canonicalPath(name string) (string, error) {
+	if len(r.abs) == 0 {
+		return r.songPath(name), nil
+	}
+	real, err := filepath.EvalSymlinks(filepath.Join(r.abs, filepath.FromSlash(name)))
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(r.wd, real)
+	if err != nil {
+		return filepath.ToSlash(real), nil // on another volume
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// folderContains determines if the other absolute folder is the same as or inside the folder
+func folderContains(folder, other string) bool {
+	rel, err := filepath.Rel(folder, other)
+	if err != nil {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+	return rel != ".." && !strings.HasPrefix(rel, "../")
+}
+
+// rootsFlag collects the library roots from repeated flags
+type rootsFlag []string
+
+// String lists the roots
+func (f *rootsFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return strings.Join(*f, ", ")
+}
+
+// Set adds a root
+func (f *rootsFlag) Set(value string) error {
+	if len(value) == 0 {
+		return fmt.Errorf("library root cannot be empty")
+	}
+	*f = append(*f, value)
+	return nil
+}
+
+// names are the roots to read songs from, the working directory if the flag is not set
+func (f rootsFlag) names() []string {
+	if len(f) == 0 {
+		return []string{"."}
+	}
+	return f
+}
+
+// isAbsolutePath determines if the slash-separated path is absolute, including paths with windows drive letters
+func isAbsolutePath(p string) bool {
+	return strings.HasPrefix(p, "/") || (len(p) > 2 && p[1] == ':' && p[2] == '/')
+}
+
+// relativePath finds the slash-separated path to the target from the base folder.
+// Both paths must be relative to the same folder or be absolute.
+// The target is returned if no relative path can be found, such as when it is on another volume.
+func relativePath(base, target string) string {
+	if isAbsolutePath(base) != isAbsolutePath(target) {
+		return target
+	}
+	split := func(p string) []string {
+		p = path.Clean(p)
+		if p == "." {
+			return nil
+		}
+		return strings.Split(p, "/")
+	}
+	b, t := split(base), split(target)
+	i := 0
+	for i < len(b) && i < len(t) && b[i] == t[i] {
+		i++
+	}
+	if i == 0 && isAbsolutePath(target) && len(b) != 0 && len(t) != 0 && b[0] != t[0] {
+		return target // different volumes
+	}
+	parts := make([]string, 0, len(b)-i+len(t)-i)
+	for _, e := range b[i:] {
+		if e == ".." {
+			return target // the name of the folder above the base is not known
+		}
+		parts = append(parts, "..")
+	}
+	parts = append(parts, t[i:]...)
+	if len(parts) == 0 {
+		return "."
+	}
+	return strings.Join(parts, "/")
+}
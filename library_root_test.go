@@ -0,0 +1,137 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewLibraryRoots(t *testing.T) {
+	wd := t.TempDir()
+	for _, dir := range []string{"music", "music/rock", "drive2", "other/drive3"} {
+		if err := os.MkdirAll(filepath.Join(wd, dir), 0755); err != nil {
+			t.Fatalf("creating folder: %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(wd, "a.mp3"), nil, 0644); err != nil {
+		t.Fatalf("creating file: %v", err)
+	}
+	tests := []struct {
+		name     string
+		wd       string
+		names    []string
+		wantDirs []string
+		wantErr  bool
+	}{
+		{"working directory", wd, []string{"."}, []string{""}, false},
+		{"folders", wd, []string{"music", "drive2", "other/drive3"}, []string{"music", "drive2", "other/drive3"}, false},
+		{"above working directory", filepath.Join(wd, "music"), []string{".", "../drive2", "../other/drive3"}, []string{"", "../drive2", "../other/drive3"}, false},
+		{"absolute", filepath.Join(wd, "music"), []string{filepath.Join(wd, "drive2")}, []string{"../drive2"}, false},
+		{"missing", wd, []string{"music", "missing"}, nil, true},
+		{"file", wd, []string{"a.mp3"}, nil, true},
+		{"duplicate", wd, []string{"music", "./music"}, nil, true},
+		{"inside", wd, []string{"music", "music/rock"}, nil, true},
+		{"inside working directory", wd, []string{".", "drive2"}, nil, true},
+		{"working directory inside", filepath.Join(wd, "music"), []string{"..", "."}, nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			roots, err := newLibraryRoots(test.names, test.wd)
+			switch {
+			case test.wantErr:
+				if err == nil {
+					t.Errorf("wanted error")
+				}
+			case err != nil:
+				t.Errorf("unwanted error: %v", err)
+			default:
+				dirs := make([]string, len(roots))
+				for i, r := range roots {
+					dirs[i] = r.dir
+					if r.name != test.names[i] {
+						t.Errorf("root %v name not equal: wanted %q, got %q", i, test.names[i], r.name)
+					}
+				}
+				if fmt.Sprintf("%q", test.wantDirs) != fmt.Sprintf("%q", dirs) {
+					t.Errorf("folders not equal: wanted %q, got %q", test.wantDirs, dirs)
+				}
+			}
+		})
+	}
+}
+
+func TestLibraryRootSongPath(t *testing.T) {
+	tests := []struct {
+		dir  string
+		name string
+		want string
+	}{
+		{"", "a/b.mp3", "a/b.mp3"},
+		{"music", "a/b.mp3", "music/a/b.mp3"},
+		{"../drive2", "b.mp3", "../drive2/b.mp3"},
+		{"D:/music", "b.mp3", "D:/music/b.mp3"},
+	}
+	for _, test := range tests {
+		r := libraryRoot{dir: test.dir}
+		if got := r.songPath(test.name); test.want != got {
+			t.Errorf("song path of %q in %q not equal: wanted %q, got %q", test.name, test.dir, test.want, got)
+		}
+	}
+}
+
+func TestRootsFlag(t *testing.T) {
+	tests := []struct {
+		args    []string
+		want    []string
+		wantErr bool
+	}{
+		{nil, []string{"."}, false},
+		{[]string{"-root", "/mnt/a", "-root", "../b"}, []string{"/mnt/a", "../b"}, false},
+		{[]string{"-root", ""}, nil, true},
+	}
+	for _, test := range tests {
+		var f rootsFlag
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		fs.Var(&f, "root", "")
+		err := fs.Parse(test.args)
+		switch {
+		case test.wantErr:
+			if err == nil {
+				t.Errorf("wanted error parsing %v", test.args)
+			}
+		case err != nil:
+			t.Errorf("parsing %v: %v", test.args, err)
+		case fmt.Sprint(test.want) != fmt.Sprint(f.names()):
+			t.Errorf("roots for %v not equal: wanted %v, got %v", test.args, test.want, f.names())
+		}
+	}
+}
+
+func TestRelativePath(t *testing.T) {
+	tests := []struct {
+		base   string
+		target string
+		want   string
+	}{
+		{".", "a/b.mp3", "a/b.mp3"},
+		{"lists", "a/b.mp3", "../a/b.mp3"},
+		{"a", "a/b.mp3", "b.mp3"},
+		{"a/lists/rock", "a/b.mp3", "../../b.mp3"},
+		{"lists", "../drive2/b.mp3", "../../drive2/b.mp3"},
+		{"a", "a", "."},
+		{"../lists", "a/b.mp3", "a/b.mp3"},
+		{"/mnt/a/lists", "/mnt/b/c.mp3", "../../b/c.mp3"},
+		{"lists", "/mnt/b/c.mp3", "/mnt/b/c.mp3"},
+		{"C:/music", "D:/music/c.mp3", "D:/music/c.mp3"},
+		{"C:/music/lists", "C:/music/c.mp3", "../c.mp3"},
+	}
+	for _, test := range tests {
+		if got := relativePath(test.base, test.target); test.want != got {
+			t.Errorf("path of %q from %q not equal: wanted %q, got %q", test.target, test.base, test.want, got)
+		}
+	}
+}
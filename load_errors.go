@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+// loadError is a problem with a file when the songs were loaded
+type loadError struct {
+	path string
+	kind string // one of the loadErrorKinds
+	err  string
+}
+
+// loadErrorKinds are the kinds of load errors:
+// open if the file or folder could not be opened,
+// format if the file has no tags in a supported format,
+// tags if the tags of the file could not be parsed,
+// and hash if the file could not be hashed.
+var loadErrorKinds = []string{"open", "format", "tags", "hash"}
+
+// printLoadErrors lists the problems with files when the songs were loaded: errors [kind], or exports them: errors export <filename> [kind].
+// Errors are exported as comma separated values if the filename ends with .csv, or as text otherwise.
+func (p *playlist) printLoadErrors(command string) {
+	fields := strings.Fields(command)
+	export := len(fields) != 0 && fields[0] == "export"
+	var name string
+	if export {
+		if len(fields) < 2 {
+			p.fail("Error (errors): missing export filename\n")
+			return
+		}
+		name, fields = fields[1], fields[2:]
+	}
+	if len(fields) > 1 {
+		p.fail("Error (errors): wanted at most one kind, got %q\n", strings.Join(fields, " "))
+		return
+	}
+	kind := ""
+	if len(fields) != 0 {
+		kind = strings.ToLower(fields[0])
+		if !validLoadErrorKind(kind) {
+			p.fail("Error (errors): unknown kind %q (wanted one of %v)\n", fields[0], strings.Join(loadErrorKinds, ", "))
+			return
+		}
+	}
+	loadErrors := p.filterLoadErrors(kind)
+	if export {
+		p.exportLoadErrors(name, loadErrors)
+		return
+	}
+	for _, le := range loadErrors {
+		fmt.Fprintf(p.w, "%-6v    %v: %v\n", le.kind, le.path, le.err)
+	}
+	fmt.Fprintf(p.w, "%v load errors\n", len(loadErrors))
+}
+
+// filterLoadErrors sorts the load errors of the kind by path, or all load errors if the kind is empty
+func (p *playlist) filterLoadErrors(kind string) []loadError {
+	var loadErrors []loadError
+	for _, le := range p.loadErrors {
+		if len(kind) == 0 || le.kind == kind {
+			loadErrors = append(loadErrors, le)
+		}
+	}
+	sort.SliceStable(loadErrors, func(i, j int) bool {
+		return loadErrors[i].path < loadErrors[j].path
+	})
+	return loadErrors
+}
+
+// exportLoadErrors writes the load errors to the file, replacing it if it exists
+func (p *playlist) exportLoadErrors(name string, loadErrors []loadError) {
+	f, err := p.fsys.ReplaceFile(name)
+	if err != nil {
+		p.fail("Error (errors): creating file: %v\n", err)
+		return
+	}
+	writeLoadErrors := writeLoadErrorsText
+	if strings.ToLower(path.Ext(name)) == ".csv" {
+		writeLoadErrors = writeLoadErrorsCSV
+	}
+	if err := writeLoadErrors(f, loadErrors); err != nil {
+		if a, ok := f.(aborter); ok {
+			a.Abort()
+		} else {
+			f.Close()
+		}
+		p.fail("Error (errors): writing file: %v\n", err)
+		return
+	}
+	if err := f.Close(); err != nil {
+		p.fail("Error (errors): closing file: %v\n", err)
+		return
+	}
+	fmt.Fprintf(p.w, "wrote %v load errors to %v\n", len(loadErrors), name)
+}
+
+// writeLoadErrorsText writes a line for each load error, separating the fields with tabs
+func writeLoadErrorsText(w io.Writer, loadErrors []loadError) error {
+	for _, le := range loadErrors {
+		if _, err := fmt.Fprintf(w, "%v\t%v\t%v\n", le.path, le.kind, le.err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeLoadErrorsCSV writes the load errors as comma separated values with a header
+func writeLoadErrorsCSV(w io.Writer, loadErrors []loadError) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"path", "kind", "error"})
+	for _, le := range loadErrors {
+		cw.Write([]string{le.path, le.kind, le.err})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// validLoadErrorKind determines if the kind is one of the kinds of load errors
+func validLoadErrorKind(kind string) bool {
+	for _, k := range loadErrorKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
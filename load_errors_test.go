@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestPlaylistPrintLoadErrors(t *testing.T) {
+	loadErrors := []loadError{
+		{path: "b.mp3", kind: "tags", err: "parsing tags: unexpected EOF"},
+		{path: "a.flac", kind: "format", err: "parsing tags: no tags found"},
+		{path: "c.mp3", kind: "open", err: "permission denied"},
+	}
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{
+			command: "",
+			want: "format    a.flac: parsing tags: no tags found\n" +
+				"tags      b.mp3: parsing tags: unexpected EOF\n" +
+				"open      c.mp3: permission denied\n" +
+				"3 load errors\n",
+		},
+		{
+			command: "TAGS",
+			want: "tags      b.mp3: parsing tags: unexpected EOF\n" +
+				"1 load errors\n",
+		},
+		{
+			command: "hash",
+			want:    "0 load errors\n",
+		},
+		{
+			command: "size",
+			want:    "Error (errors): unknown kind \"size\" (wanted one of open, format, tags, hash)\n",
+		},
+		{
+			command: "tags open",
+			want:    "Error (errors): wanted at most one kind, got \"tags open\"\n",
+		},
+		{
+			command: "export",
+			want:    "Error (errors): missing export filename\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.command, func(t *testing.T) {
+			var w bytes.Buffer
+			p := playlist{
+				loadErrors: loadErrors,
+				w:          &w,
+			}
+			p.printLoadErrors(test.command)
+			if want, got := test.want, w.String(); want != got {
+				t.Errorf("output not equal: \n wanted: %q \n got:    %q", want, got)
+			}
+		})
+	}
+}
+
+func TestPlaylistExportLoadErrors(t *testing.T) {
+	loadErrors := []loadError{
+		{path: "b.mp3", kind: "tags", err: "parsing tags: unexpected EOF"},
+		{path: "a, b.flac", kind: "format", err: "parsing tags: no tags found"},
+	}
+	tests := []struct {
+		name      string
+		command   string
+		writeErr  bool
+		wantFile  string
+		wantOut   string
+		wantAbort bool
+	}{
+		{
+			name:     "text",
+			command:  "export errors.txt",
+			wantFile: "a, b.flac\tformat\tparsing tags: no tags found\nb.mp3\ttags\tparsing tags: unexpected EOF\n",
+			wantOut:  "wrote 2 load errors to errors.txt\n",
+		},
+		{
+			name:     "csv of kind",
+			command:  "export errors.CSV format",
+			wantFile: "path,kind,error\n\"a, b.flac\",format,parsing tags: no tags found\n",
+			wantOut:  "wrote 1 load errors to errors.CSV\n",
+		},
+		{
+			name:      "write error",
+			command:   "export errors.txt",
+			writeErr:  true,
+			wantOut:   "Error (errors): writing file: ",
+			wantAbort: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var file, out bytes.Buffer
+			var fw io.Writer = &file
+			if test.writeErr {
+				fw = errWriter{}
+			}
+			aborted := false
+			var replaced []string
+			p := playlist{
+				loadErrors: loadErrors,
+				w:          &out,
+				fsys: MockPlaylistFS{
+					ReplaceFileFunc: func(name string) (io.WriteCloser, error) {
+						replaced = append(replaced, name)
+						f := mockAbortWriteCloser{
+							MockWriteCloser: MockWriteCloser{
+								Writer:    fw,
+								CloseFunc: func() error { return nil },
+							},
+							AbortFunc: func() error {
+								aborted = true
+								return nil
+							},
+						}
+						return f, nil
+					},
+				},
+			}
+			p.printLoadErrors(test.command)
+			switch {
+			case len(replaced) != 1:
+				t.Errorf("wanted one file to be replaced, got %v", replaced)
+			case !strings.HasPrefix(out.String(), test.wantOut):
+				t.Errorf("output not equal: \n wanted: %q \n got:    %q", test.wantOut, out.String())
+			case test.wantFile != file.String():
+				t.Errorf("files not equal: \n wanted: %q \n got:    %q", test.wantFile, file.String())
+			case test.wantAbort != aborted:
+				t.Errorf("wanted abort: %v, got %v", test.wantAbort, aborted)
+			}
+		})
+	}
+	t.Run("create error", func(t *testing.T) {
+		var out bytes.Buffer
+		p := playlist{
+			w: &out,
+			fsys: MockPlaylistFS{
+				ReplaceFileFunc: func(name string) (io.WriteCloser, error) {
+					return nil, fmt.Errorf("mock create error")
+				},
+			},
+		}
+		p.printLoadErrors("export a.txt")
+		if want, got := "Error (errors): creating file: mock create error\n", out.String(); want != got {
+			t.Errorf("output not equal: \n wanted: %q \n got:    %q", want, got)
+		}
+	})
+}
+
+// errWriter fails every write
+type errWriter struct{}
+
+func (errWriter) Write(p []byte) (int, error) {
+	return 0, fmt.Errorf("mock write error")
+}
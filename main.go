@@ -2,11 +2,16 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"io/fs"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"runtime"
 	"strings"
 )
@@ -16,66 +21,293 @@ func main() {
 	w := os.Stdout
 	var showHash bool
 	var loadThreads int
-	flag.BoolVar(&showHash, "md5", false, "load md5sums for songs")
+	var cachePath string
+	var scriptPath string
+	var scriptCommands commandsFlag
+	var keepGoing bool
+	var templateTexts pathTemplatesFlag
+	var rootNames rootsFlag
+	var includes, excludes patternsFlag
+	var extensionsText string
+	var hashName string
+	var hashAudio bool
+	var partial bool
+	var followLinks bool
+	var progressMode string
+	flag.BoolVar(&showHash, "md5", false, "load md5sums for songs, the same as -hash md5")
+	flag.StringVar(&hashName, "hash", "", "hash to load for songs, one of "+songHashNames())
+	flag.BoolVar(&hashAudio, "hash-audio", false, "only hash the audio of mp3, m4a, and flac songs, excluding tags, so retagged copies have the same hash, other songs and songs without audio that can be found are hashed whole (md5 if -hash is not set)")
 	flag.IntVar(&loadThreads, "loadThreads", runtime.NumCPU(), "number of load threads")
+	flag.StringVar(&cachePath, "cache", ".m3u-playlist-creator.cache", "file to store song metadata in to speed up loading, disabled if empty")
+	flag.StringVar(&scriptPath, "script", "", "file of commands to run instead of reading commands interactively, - for standard input")
+	flag.Var(&scriptCommands, "c", "commands to run instead of reading commands interactively, separated by semicolons, can be repeated to run more commands in order, use -script for commands with semicolons")
+	flag.BoolVar(&keepGoing, "keep-going", false, "continue running script commands after a command fails")
+	flag.StringVar(&progressMode, "progress", "auto", "how the progress of loading songs is displayed: auto, tty (a bar on one line), lines (for logs), or quiet, auto uses tty if the output is a terminal")
+	flag.BoolVar(&partial, "partial", false, "use the songs that were loaded if loading is interrupted with Ctrl-C")
+	flag.StringVar(&extensionsText, "ext", strings.Join(songExtensions, ","), "comma separated extensions of song files to load")
+	flag.Var(&rootNames, "root", "folder to load songs from, can be repeated to load songs from several folders, which label the songs (default .)")
+	flag.BoolVar(&followLinks, "follow-symlinks", false, "load songs in folders that are symbolic links, writing the paths of songs with the links resolved to playlists")
+	flag.Var(&includes, "include", "gitignore-style pattern of song files to load, such as rock/** or *.flac, can be repeated, all song files are loaded if not set")
+	flag.Var(&excludes, "exclude", "gitignore-style pattern of files and folders to skip when loading songs, such as ._* or .Trashes/, can be repeated, in addition to the patterns in "+ignoreFileName+" files")
+	flag.Var(&templateTexts, "template", "path template to infer the metadata of songs with unreadable tags, such as {artist}/{album}/{track} - {title}.mp3, can be repeated, disabled if empty (default "+strings.Join(defaultPathTemplates, ", ")+")")
 	flag.Parse()
+	templates, err := newPathTemplates(templateTexts.texts())
+	if err != nil {
+		fmt.Fprintf(w, "Error (reading flags): %v\n", err)
+		os.Exit(2)
+	}
+	extensions, err := parseSongExtensions(extensionsText)
+	if err != nil {
+		fmt.Fprintf(w, "Error (reading flags): %v\n", err)
+		os.Exit(2)
+	}
+	filter, err := newPathFilter(includes, excludes)
+	if err != nil {
+		fmt.Fprintf(w, "Error (reading flags): %v\n", err)
+		os.Exit(2)
+	}
+	progressMode, err = parseProgressMode(progressMode, w)
+	if err != nil {
+		fmt.Fprintf(w, "Error (reading flags): %v\n", err)
+		os.Exit(2)
+	}
+	var hasher *songHasher
+	if len(hashName) == 0 && (showHash || hashAudio) {
+		hashName = "md5"
+	}
+	if len(hashName) != 0 {
+		hasher, err = newSongHasher(hashName, hashAudio)
+		if err != nil {
+			fmt.Fprintf(w, "Error (reading flags): %v\n", err)
+			os.Exit(2)
+		}
+		showHash = true
+		if whole := wholeFileAudioExtensions(extensions); hashAudio && len(whole) != 0 {
+			fmt.Fprintf(w, "-hash-audio hashes %v songs whole because their audio cannot be separated from their tags\n", strings.Join(whole, ", "))
+		}
+	}
+	var script io.Reader
+	switch {
+	case len(scriptPath) != 0 && len(scriptCommands) != 0:
+		fmt.Fprintf(w, "Error (reading flags): only one of -script and -c can be used\n")
+		os.Exit(2)
+	case scriptPath == "-":
+		script = r
+	case len(scriptPath) != 0:
+		b, err := os.ReadFile(scriptPath)
+		if err != nil {
+			fmt.Fprintf(w, "Error (reading script): %v\n", err)
+			os.Exit(1)
+		}
+		script = bytes.NewReader(b)
+	case len(scriptCommands) != 0:
+		script = scriptCommands.script()
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		fmt.Fprintf(w, "Error (getting working directory): %v\n", err)
+		os.Exit(1)
+	}
+	roots, err := newLibraryRoots(rootNames.names(), wd)
+	if err != nil {
+		fmt.Fprintf(w, "Error (reading flags): %v\n", err)
+		os.Exit(2)
+	}
 	fs := os.DirFS(".")
+	var cache *songCache
+	if len(cachePath) != 0 {
+		cache = loadSongCache(cachePath, w)
+	}
 	sr := songReader{
-		fsys:         fs,
-		addHash:      showHash,
+		roots:        roots,
+		hasher:       hasher,
 		loadThreads:  loadThreads,
-		pathSuffixes: []string{".mp3", ".m4a"},
-		// MP3, M4A, M4B, M4P, ALAC, FLAC, OGG, and DSF is supported by github.com/dhowden/tag
+		pathSuffixes: extensions,
+		filter:       filter,
+		cache:        cache,
+		templates:    templates,
+		progressMode: progressMode,
+		followLinks:  followLinks,
+	}
+	songs, loadErrors, err := readLibrary(sr, cachePath, w)
+	if errors.Is(err, context.Canceled) && partial {
+		fmt.Fprintf(w, "> using the %v songs loaded before the interrupt\n", len(songs))
+		err = nil
 	}
-	songs, err := sr.readSongs(w)
+	ok := true
 	switch {
 	case err != nil:
 		fmt.Fprintf(w, "Error (reading songs): %v\n", err)
+		ok = false
 	case len(songs) == 0:
 		fmt.Fprintf(w, "no songs in folder to add to playlists\n")
+		ok = script == nil
 	default:
 		fsys := osFS{
-			FS: fs,
+			FS:           fs,
+			root:         filepath.ToSlash(wd),
+			libraryRoots: roots,
 			createFileFunc: func(name string) (io.WriteCloser, error) {
 				return os.Create(name)
 			},
+			replaceFileFunc: func(name string) (io.WriteCloser, error) {
+				return createAtomicFile(name)
+			},
+			readSongsFunc: func(w io.Writer) ([]song, []loadError, error) {
+				return readLibrary(sr, cachePath, w)
+			},
+		}
+		var movedSongs map[string]song
+		if cache != nil {
+			movedSongs = cache.removedSongs(sr.hashKind())
+		}
+		if script == nil {
+			fsys.runPlaylistCreator(songs, movedSongs, loadErrors, r, w, showHash)
+			break
+		}
+		if err := fsys.runScript(songs, movedSongs, loadErrors, script, w, showHash, keepGoing); err != nil {
+			fmt.Fprintf(w, "Error (running script): %v\n", err)
+			ok = false
 		}
-		fsys.runPlaylistCreator(songs, r, w, showHash)
+	}
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+// readLibrary reads the songs, stopping if the application is interrupted.
+// The cache is saved with the songs that were read, even if reading was stopped.
+func readLibrary(sr songReader, cachePath string, w io.Writer) ([]song, []loadError, error) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop() // interrupt signals quit the application after loading
+	songs, loadErrors, err := sr.readSongs(ctx, w)
+	if (err == nil || errors.Is(err, context.Canceled)) && sr.cache != nil {
+		saveSongCache(sr.cache, cachePath, w)
+	}
+	return songs, loadErrors, err
+}
+
+// loadSongCache reads the song cache file, returning an empty cache if it is missing or not valid.
+// The file is opened like it is saved, so it can be outside the working directory.
+func loadSongCache(name string, w io.Writer) *songCache {
+	cache := newSongCache()
+	f, err := os.Open(name)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			fmt.Fprintf(w, "Error (loading song cache): %v; reading all songs\n", err)
+		}
+		return cache
+	}
+	defer f.Close()
+	if _, err := cache.ReadFrom(f); err != nil {
+		fmt.Fprintf(w, "Error (loading song cache): %v; reading all songs\n", err)
+	}
+	return cache
+}
+
+// saveSongCache writes the song cache file, replacing the previous one
+func saveSongCache(cache *songCache, name string, w io.Writer) {
+	f, err := os.Create(name)
+	if err != nil {
+		fmt.Fprintf(w, "Error (saving song cache): creating file: %v\n", err)
+		return
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			fmt.Fprintf(w, "Error (closing %q): %v\n", name, err)
+		}
+	}()
+	if _, err := cache.WriteTo(f); err != nil {
+		fmt.Fprintf(w, "Error (saving song cache): %v\n", err)
 	}
 }
 
 type osFS struct {
 	fs.FS
-	createFileFunc func(name string) (io.WriteCloser, error)
+	root            string        // the absolute, slash-separated path of the file system
+	libraryRoots    []libraryRoot // the folders songs are read from
+	createFileFunc  func(name string) (io.WriteCloser, error)
+	replaceFileFunc func(name string) (io.WriteCloser, error)
+	readSongsFunc   func(w io.Writer) ([]song, []loadError, error) // reads the songs in the file system again
 }
 
 func (fsys *osFS) CreateFile(name string) (io.WriteCloser, error) {
 	if !fs.ValidPath(name) {
-		return nil, fmt.Errorf("%q bust be relative to application root", name)
+		return nil, fmt.Errorf("%q must be relative to application root", name)
 	}
 	_, err := fs.Stat(fsys, name)
 	if _, ok := err.(*os.PathError); !ok {
-		return nil, fmt.Errorf("%q already exists or could not be checked: %v", name, err)
+		return nil, fmt.Errorf("%q already exists or could not be checked (use w! to overwrite): %v", name, err)
 	}
 	return fsys.createFileFunc(name)
 }
 
-func (fsys *osFS) runPlaylistCreator(songs []song, r io.Reader, w io.Writer, showHash bool) {
+// ReplaceFile creates a file that safely replaces the file when it is closed, if it exists
+func (fsys *osFS) ReplaceFile(name string) (io.WriteCloser, error) {
+	if !fs.ValidPath(name) {
+		return nil, fmt.Errorf("%q must be relative to application root", name)
+	}
+	return fsys.replaceFileFunc(name)
+}
+
+// runPlaylistCreator evaluates commands to create playlists of the songs.
+// Moved songs are songs that were cached but no longer exist, by path, which help relink missing tracks.
+// Load errors are the problems with files when the songs were loaded.
+func (fsys *osFS) runPlaylistCreator(songs []song, movedSongs map[string]song, loadErrors []loadError, r io.Reader, w io.Writer, showHash bool) {
+	p, cmds := fsys.newPlaylistCommands(songs, movedSongs, loadErrors, w, showHash)
+	s := bufio.NewScanner(r)
+	p.confirm = func(question string) bool {
+		fmt.Fprintf(w, "%v [y/N] ", question)
+		if !s.Scan() {
+			return false
+		}
+		answer := strings.ToLower(strings.TrimSpace(s.Text()))
+		return answer == "y" || answer == "yes"
+	}
+	cmds.displayHelp(w)
+	canQuit := func() bool {
+		return p.confirmDiscard("Quit")
+	}
+	cmds.run(s, w, canQuit)
+}
+
+// newPlaylistCommands creates a playlist of the songs and the commands to change it
+func (fsys *osFS) newPlaylistCommands(songs []song, movedSongs map[string]song, loadErrors []loadError, w io.Writer, showHash bool) (*playlist, commands) {
 	p := newPlaylist(songs, fsys, w, showHash)
+	p.root = fsys.root
+	p.libraryRoots = fsys.libraryRoots
+	p.moved = movedSongs
+	p.loadErrors = loadErrors
+	p.readSongs = fsys.readSongsFunc
 	cmds := commands{
-		{"f", p.filter, "Filter songs with query: f <query>"},
+		{"f", p.filter, "Filter songs with query: f <query>, such as f artist:beck OR (album:guero NOT track:<3)"},
+		{"ff", p.fuzzyFilter, "Filter songs by similar artists, albums, or titles, ranked by score: ff <words>, such as ff beatels abbey, misspelled words and the starts of words match"},
+		{"fuzzy", p.setFuzzyThreshold, "Sets the minimum score of songs that match ff filters: fuzzy [score], from 0 to 100, displays the score if it is not given"},
 		{"d", p.printSongFilter, "Display filter'd songs by id"},
-		{"a", p.addTrack, "Add song song by filter id: a <id>"},
+		{"a", p.addTrack, "Add songs by filter ids: a <ids> [@ <index>], such as a 1-5,8,12- or a * @ 2 to add all songs before the second track"},
 		{"m", p.moveTrack, "Move playlist track: m <old_index> <new_index>"},
 		{"r", p.removeTrack, "Remove playlist track: r <index>"},
 		{"n", p.renameTrack, "Rename playlist track: n <index> <name>"},
 		{"c", p.clearTracks, "Clear playlist tracks"},
+		{"dedupe", p.dedupe, "Removes playlist tracks that are duplicates of earlier tracks: dedupe [path|hash|tags], tags compares artists and titles"},
+		{"dupes", p.printDuplicates, "Lists duplicate songs in the library: dupes [hash|tags], by tags and hash (with -md5 or -hash) by default"},
+		{"sort", p.sortTracks, "Sorts playlist tracks: sort [-]<key>,..., such as sort artist,album,track or sort -title, keys are " + trackSortKeyNames()},
+		{"shuffle", p.shuffle, "Shuffles playlist tracks: shuffle [artist|album|spread] [seed], artist keeps tracks by the same artist apart, album keeps albums together, spread spreads out each artist"},
+		{"cols", p.setColumns, "Sets the optional columns of the song and track tables: cols [name,...], names are " + songColumnNames() + ", no names removes the columns"},
 		{"p", p.printTracks, "Print playlist tracks and indexes"},
-		{"l", p.load, "Loads playlist: l <filename>"},
-		{"w", p.write, "Writes playlist: w <filename>"},
+		{"l", p.load, "Loads playlist: l <filename>, the format is determined by the extension (" + playlistExtensions() + ")"},
+		{"w", p.write, "Writes playlist to a new file: w <filename>, the format is determined by the extension (" + playlistExtensions() + ")"},
+		{"w!", p.overwrite, "Writes playlist, replacing the file if it exists: w! <filename>, the previous file is kept as <filename>.bak"},
+		{"s", p.save, "Saves playlist to the file it was last loaded from or written to, the previous file is kept as a .bak file"},
+		{"rescan", p.rescan, "Reads the songs in the library again, listing the files that were added (+), removed (-), or changed (~), tracks of removed songs are marked as missing"},
+		{"errors", p.printLoadErrors, "Lists the problems with files when the songs were loaded: errors [kind], or writes them to a file: errors export <filename> [kind], as csv if the filename ends with .csv, kinds are " + strings.Join(loadErrorKinds, ", ")},
+		{"relink", p.relink, "Asks which songs replace the tracks that are missing, such as songs that were moved: relink [best], best uses the best match of each track without asking"},
+		{"u", p.undo, "Undo the last change to the playlist tracks"},
+		{"U", p.redo, "Redo the last change to the playlist tracks that was undone"},
+		{"redo", p.redo, "Redo the last change to the playlist tracks that was undone"},
+		{"history", p.printHistory, "Lists the changes to the playlist tracks that can be undone and redone"},
 	}
-	cmds.displayHelp(w)
-	cmds.run(r, w)
+	return p, cmds
 }
 
 type (
@@ -100,50 +332,67 @@ func (cmds commands) displayHelp(w io.Writer) {
 		lines = append(lines, c.key+tab+c.info)
 	}
 	lines = append(lines, "h"+tab+"Help information is printed.")
-	lines = append(lines, "q"+tab+"Quits the application.")
+	lines = append(lines, "q"+tab+"Quits the application, asking first if the playlist has unsaved changes.")
 	for _, l := range lines {
 		fmt.Fprintln(w, l)
 	}
 }
 
-func (cmds commands) run(r io.Reader, w io.Writer) {
-	cmdsCap := len(cmds) + 2
-	lookup := make(map[string]func(string), cmdsCap)
-	for _, c := range cmds {
-		lookup[c.key] = c.run
-	}
-	lookup["h"] = func(s string) {
-		cmds.displayHelp(w)
-	}
-	lookup["q"] = nil
-	if len(lookup) != cmdsCap {
-		fmt.Fprintf(w, "Error (preparing to run commands): some commands have duplicate keys: wanted %v, got %v", cmdsCap, len(lookup))
+// run evaluates commands from the scanner until the input ends or the user quits.
+// The optional canQuit function is checked before quitting.
+func (cmds commands) run(s *bufio.Scanner, w io.Writer, canQuit func() bool) {
+	lookup, err := cmds.lookup(w)
+	if err != nil {
+		fmt.Fprintf(w, "Error (preparing to run commands): %v", err)
 		return
 	}
-	s := bufio.NewScanner(r)
-	var (
-		line, key, args string
-		commandTokens   []string
-		cmd             func(s string)
-		ok              bool
-	)
 	for {
 		fmt.Fprintf(w, "> ")
 		if !s.Scan() {
 			return
 		}
-		line = s.Text()
+		line := s.Text()
 		if line == "q" {
-			return
-		}
-		if commandTokens = strings.Fields(line); len(commandTokens) != 0 {
-			key, args = commandTokens[0], strings.TrimSpace(line[len(commandTokens[0]):])
-			cmd, ok = lookup[key]
-			if ok {
-				cmd(args)
-				continue
+			if canQuit == nil || canQuit() {
+				return
 			}
+			continue
+		}
+		if !runLine(lookup, line) {
+			fmt.Fprintf(w, "Error (invalid command): %v\n", line)
 		}
-		fmt.Fprintf(w, "Error (invalid command): %v\n", line)
 	}
 }
+
+// lookup maps the keys of the commands to the functions that run them, including help and quit
+func (cmds commands) lookup(w io.Writer) (map[string]func(string), error) {
+	cmdsCap := len(cmds) + 2
+	lookup := make(map[string]func(string), cmdsCap)
+	for _, c := range cmds {
+		lookup[c.key] = c.run
+	}
+	lookup["h"] = func(s string) {
+		cmds.displayHelp(w)
+	}
+	lookup["q"] = nil // handled by the caller
+	if len(lookup) != cmdsCap {
+		return nil, fmt.Errorf("some commands have duplicate keys: wanted %v, got %v", cmdsCap, len(lookup))
+	}
+	return lookup, nil
+}
+
+// runLine runs the command on the line, returning false if the line is not a valid command
+func runLine(lookup map[string]func(string), line string) bool {
+	line = strings.TrimSpace(line)
+	commandTokens := strings.Fields(line)
+	if len(commandTokens) == 0 {
+		return false
+	}
+	key, args := commandTokens[0], strings.TrimSpace(line[len(commandTokens[0]):])
+	cmd, ok := lookup[key]
+	if !ok || cmd == nil {
+		return false
+	}
+	cmd(args)
+	return true
+}
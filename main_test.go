@@ -1,8 +1,11 @@
 package main
 
 import (
+	"bufio"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"testing/fstest"
@@ -74,13 +77,51 @@ func TestOsFSCreateFile(t *testing.T) {
 	}
 }
 
+func TestOsFSReplaceFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		destPath string
+		wantErr  bool
+	}{
+		{"not child of root", "/e/g/list.m3u", true},
+		{"file exists", "list.m3u", false},
+		{"new file", "new.m3u", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fsys := osFS{
+				FS: fstest.MapFS{
+					"list.m3u": &fstest.MapFile{},
+				},
+				replaceFileFunc: func(name string) (io.WriteCloser, error) {
+					if want, got := test.destPath, name; want != got {
+						return nil, fmt.Errorf("file names not equal: \n wanted: %v \n got:    %q", want, got)
+					}
+					return new(MockWriteCloser), nil
+				},
+			}
+			w, err := fsys.ReplaceFile(test.destPath)
+			switch {
+			case test.wantErr:
+				if err == nil {
+					t.Error("wanted error")
+				}
+			case err != nil:
+				t.Errorf("unwanted error: %v", err)
+			case w == nil:
+				t.Error("file not created")
+			}
+		})
+	}
+}
+
 func TestRunPlaylistCreator(t *testing.T) {
 	t.Run("EOF", func(t *testing.T) {
 		r := strings.NewReader("")
 		w := io.Discard
 		var fsys osFS
 		var songs []song
-		fsys.runPlaylistCreator(songs, r, w, false)
+		fsys.runPlaylistCreator(songs, nil, nil, r, w, false)
 	})
 	t.Run("many", func(t *testing.T) {
 		songs := []song{
@@ -100,6 +141,9 @@ func TestRunPlaylistCreator(t *testing.T) {
 			"m 2 1",      // move 'e' to the top
 			"r 2",        // remove 'd'
 			"n 1 song",   // rename 'e'
+			"u",          // undo rename
+			"U",          // redo rename
+			"history",    // print changes
 			"p",          // print tracks
 			"?",          // invalid command
 			"w curr.m3u", // write the playlist
@@ -132,7 +176,7 @@ func TestRunPlaylistCreator(t *testing.T) {
 				return &f, nil
 			},
 		}
-		fsys.runPlaylistCreator(songs, input, &output, false)
+		fsys.runPlaylistCreator(songs, nil, nil, input, &output, false)
 		switch {
 		case output.Len() == 0:
 			t.Errorf("no output written")
@@ -165,7 +209,7 @@ func TestRunCommands(t *testing.T) {
 				}
 				input := strings.NewReader(test.line)
 				var output strings.Builder
-				cmds.run(input, &output)
+				cmds.run(bufio.NewScanner(input), &output, nil)
 				got := output.String()
 				gotValid := !strings.Contains(got, "invalid command")
 				if test.wantValid != gotValid {
@@ -175,3 +219,92 @@ func TestRunCommands(t *testing.T) {
 		}
 	})
 }
+
+func TestRunCommandsQuit(t *testing.T) {
+	tests := []struct {
+		name          string
+		input         string
+		canQuit       []bool
+		wantQuitCalls int
+		wantRan       int
+	}{
+		{"no check", "q\na", nil, 0, 0},
+		{"can quit", "q\na", []bool{true}, 1, 0},
+		{"cancel quit", "q\na\nq\na", []bool{false, true}, 2, 1},
+		{"end of input", "q\na", []bool{false}, 1, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ran := 0
+			cmds := commands{
+				{"a", func(command string) { ran++ }, ""},
+			}
+			var canQuit func() bool
+			quitCalls := 0
+			if test.canQuit != nil {
+				canQuit = func() bool {
+					quitCalls++
+					return test.canQuit[quitCalls-1]
+				}
+			}
+			input := strings.NewReader(test.input)
+			cmds.run(bufio.NewScanner(input), io.Discard, canQuit)
+			if test.wantQuitCalls != quitCalls || test.wantRan != ran {
+				t.Errorf("wanted %v quit checks and %v commands run, got %v and %v", test.wantQuitCalls, test.wantRan, quitCalls, ran)
+			}
+		})
+	}
+}
+
+func TestLoadSongCache(t *testing.T) {
+	dir := t.TempDir() // an absolute path outside the working directory
+	files := map[string]string{
+		"corrupt.cache": "{",
+		"ok.cache":      fmt.Sprintf(`{"version":%d,"entries":{"a.mp3":{}}}`, songCacheVersion),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatalf("writing cache file: %v", err)
+		}
+	}
+	tests := []struct {
+		name        string
+		cachePath   string
+		wantEntries int
+		wantErr     bool
+	}{
+		{
+			name:      "missing",
+			cachePath: filepath.Join(dir, "missing.cache"),
+		},
+		{
+			name:      "not a folder",
+			cachePath: filepath.Join(dir, "ok.cache", "songs.cache"),
+			wantErr:   true,
+		},
+		{
+			name:      "corrupt",
+			cachePath: filepath.Join(dir, "corrupt.cache"),
+			wantErr:   true,
+		},
+		{
+			name:        "ok",
+			cachePath:   filepath.Join(dir, "ok.cache"),
+			wantEntries: 1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var w strings.Builder
+			c := loadSongCache(test.cachePath, &w)
+			switch {
+			case c == nil:
+				t.Errorf("wanted cache")
+			case test.wantEntries != len(c.entries):
+				t.Errorf("entry counts not equal: wanted %v, got %v", test.wantEntries, len(c.entries))
+			case test.wantErr != (w.Len() != 0):
+				t.Errorf("wanted logged error: %v, got %q", test.wantErr, w.String())
+			}
+		})
+	}
+}
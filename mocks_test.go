@@ -8,6 +8,7 @@ import (
 	"io"
 	"io/fs"
 	"testing"
+	"time"
 )
 
 //go:embed empty_audacity.mp3
@@ -18,6 +19,8 @@ const (
 	emptyMp3Title  = "MY_TITLE00"
 	emptyMp3Album  = "MY_ALBUM00"
 	emptyMp3Artist = "MY_ARTIST0"
+	// emptyMp3Duration is the length of the single mono 44.1kHz frame in the mp3: 1152/44100 seconds
+	emptyMp3Duration = 26122448 * time.Nanosecond
 )
 
 func mockMp3(s song) []byte {
@@ -76,13 +79,18 @@ func playlistsEqual(t *testing.T, a, b playlist) bool {
 
 type MockPlaylistFS struct {
 	fs.FS
-	CreateFileFunc func(name string) (io.WriteCloser, error)
+	CreateFileFunc  func(name string) (io.WriteCloser, error)
+	ReplaceFileFunc func(name string) (io.WriteCloser, error)
 }
 
 func (fsys MockPlaylistFS) CreateFile(name string) (io.WriteCloser, error) {
 	return fsys.CreateFileFunc(name)
 }
 
+func (fsys MockPlaylistFS) ReplaceFile(name string) (io.WriteCloser, error) {
+	return fsys.ReplaceFileFunc(name)
+}
+
 // MockWriteCloser is a Writer that is also a Closer that delegates to a helper function.
 type MockWriteCloser struct {
 	io.Writer
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// ignoreFileName is the name of the files with patterns of paths to skip when reading songs.
+// The patterns are relative to the folder of the file.
+const ignoreFileName = ".m3uignore"
+
+// pathPattern is a gitignore-style glob that matches slash-separated paths
+type pathPattern struct {
+	base     string   // the folder the pattern is relative to, empty for the library root
+	glob     []string // the parts of the pattern, ** matches any number of folders
+	negate   bool     // the pattern starts with !, which includes paths that were excluded by earlier patterns
+	dirOnly  bool     // the pattern ends with /, which only matches folders
+	anchored bool     // the pattern has a slash before its end, so it matches the path relative to the base, otherwise it matches the names of files and folders
+}
+
+// newPathPattern parses the pattern relative to the base folder
+func newPathPattern(base, text string) (pathPattern, error) {
+	pp := pathPattern{
+		base: base,
+	}
+	if strings.HasPrefix(text, "!") {
+		pp.negate = true
+		text = text[1:]
+	}
+	if strings.HasSuffix(text, "/") {
+		pp.dirOnly = true
+		text = strings.TrimRight(text, "/")
+	}
+	if strings.Contains(text, "/") {
+		pp.anchored = true
+		text = strings.TrimPrefix(text, "/")
+	}
+	if len(text) == 0 {
+		return pathPattern{}, fmt.Errorf("empty path pattern")
+	}
+	pp.glob = strings.Split(text, "/")
+	for _, g := range pp.glob {
+		if _, err := path.Match(g, ""); err != nil {
+			return pathPattern{}, fmt.Errorf("path pattern %q: %v", text, err)
+		}
+	}
+	return pp, nil
+}
+
+// parsePathPatterns reads the patterns on the lines of an ignore file, skipping blank lines and comments that start with #
+func parsePathPatterns(base string, r io.Reader) ([]pathPattern, error) {
+	var patterns []pathPattern
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		pp, err := newPathPattern(base, line)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, pp)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return patterns, nil
+}
+
+// readIgnoreFile reads the patterns of the ignore file in the folder, if it exists
+func readIgnoreFile(fsys fs.FS, dir string) ([]pathPattern, error) {
+	f, err := fsys.Open(path.Join(dir, ignoreFileName))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	base := dir
+	if base == "." {
+		base = ""
+	}
+	return parsePathPatterns(base, f)
+}
+
+// matches determines if the pattern matches the path of the file or folder
+func (pp pathPattern) matches(p string, isDir bool) bool {
+	if pp.dirOnly && !isDir {
+		return false
+	}
+	if len(pp.base) != 0 {
+		if !strings.HasPrefix(p, pp.base+"/") {
+			return false
+		}
+		p = p[len(pp.base)+1:]
+	}
+	if !pp.anchored {
+		ok, _ := path.Match(pp.glob[0], path.Base(p))
+		return ok
+	}
+	return matchGlobParts(pp.glob, strings.Split(p, "/"))
+}
+
+// matchGlobParts determines if the parts of the glob match the parts of the path
+func matchGlobParts(glob, parts []string) bool {
+	for ; len(glob) != 0; glob, parts = glob[1:], parts[1:] {
+		if glob[0] == "**" {
+			for i := 0; i <= len(parts); i++ {
+				if matchGlobParts(glob[1:], parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(glob[0], parts[0]); !ok {
+			return false
+		}
+	}
+	return len(parts) == 0
+}
+
+// matchPathPatterns determines if the last of the patterns that match the path is not negated
+func matchPathPatterns(patterns []pathPattern, p string, isDir bool) bool {
+	matched := false
+	for _, pp := range patterns {
+		if pp.matches(p, isDir) {
+			matched = !pp.negate
+		}
+	}
+	return matched
+}
+
+// pathFilter chooses the paths of files to read songs from
+type pathFilter struct {
+	includes []pathPattern // files are only read if they or their folders match, all files are read if empty
+	excludes []pathPattern // files and folders are skipped if they match
+}
+
+// newPathFilter parses the include and exclude patterns, which are relative to the library roots
+func newPathFilter(includes, excludes []string) (*pathFilter, error) {
+	var f pathFilter
+	for _, p := range []struct {
+		texts    []string
+		patterns *[]pathPattern
+	}{
+		{includes, &f.includes},
+		{excludes, &f.excludes},
+	} {
+		for _, text := range p.texts {
+			pp, err := newPathPattern("", text)
+			if err != nil {
+				return nil, err
+			}
+			*p.patterns = append(*p.patterns, pp)
+		}
+	}
+	return &f, nil
+}
+
+// included determines if the file at the path, or one of its folders, matches the include patterns
+func (f pathFilter) included(p string) bool {
+	if len(f.includes) == 0 {
+		return true
+	}
+	if matchPathPatterns(f.includes, p, false) {
+		return true
+	}
+	for dir := path.Dir(p); dir != "."; dir = path.Dir(dir) {
+		if matchPathPatterns(f.includes, dir, true) {
+			return true
+		}
+	}
+	return false
+}
+
+// patternsFlag collects path patterns from repeated flags
+type patternsFlag []string
+
+// String lists the patterns
+func (f *patternsFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return strings.Join(*f, ", ")
+}
+
+// Set adds a pattern
+func (f *patternsFlag) Set(value string) error {
+	if len(value) == 0 {
+		return fmt.Errorf("path pattern cannot be empty")
+	}
+	*f = append(*f, value)
+	return nil
+}
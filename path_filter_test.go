@@ -0,0 +1,146 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestPathPatternMatches(t *testing.T) {
+	tests := []struct {
+		name    string
+		base    string
+		pattern string
+		path    string
+		isDir   bool
+		want    bool
+	}{
+		{"name", "", "._*", "._a.mp3", false, true},
+		{"name in folder", "", "._*", "a/b/._c.mp3", false, true},
+		{"name not matched", "", "._*", "a/b._c.mp3", false, false},
+		{"folder only", "", ".Trashes/", ".Trashes", true, true},
+		{"folder only file", "", ".Trashes/", ".Trashes", false, false},
+		{"anchored", "", "backup/old", "backup/old", true, true},
+		{"anchored in folder", "", "backup/old", "a/backup/old", true, false},
+		{"leading slash", "", "/backup", "backup", true, true},
+		{"leading slash in folder", "", "/backup", "a/backup", true, false},
+		{"double star", "", "**/backup", "a/b/backup", true, true},
+		{"double star at root", "", "**/backup", "backup", true, true},
+		{"double star middle", "", "a/**/c.mp3", "a/x/y/c.mp3", false, true},
+		{"double star end", "", "a/**", "a/x/y/c.mp3", false, true},
+		{"star stays in folder", "", "a/*.mp3", "a/x/c.mp3", false, false},
+		{"base", "rock", "*.bak", "rock/a/b.bak", false, true},
+		{"base other folder", "rock", "*.bak", "jazz/b.bak", false, false},
+		{"base anchored", "rock", "/live", "rock/live", true, true},
+		{"base anchored in folder", "rock", "/live", "rock/a/live", true, false},
+		{"base similar folder", "rock", "*", "rocks/a.mp3", false, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pp, err := newPathPattern(test.base, test.pattern)
+			if err != nil {
+				t.Fatalf("unwanted error: %v", err)
+			}
+			if got := pp.matches(test.path, test.isDir); test.want != got {
+				t.Errorf("wanted %q to match %q: %v, got %v", test.pattern, test.path, test.want, got)
+			}
+		})
+	}
+}
+
+func TestNewPathPatternErrors(t *testing.T) {
+	for _, text := range []string{"", "/", "!", "[a"} {
+		if _, err := newPathPattern("", text); err == nil {
+			t.Errorf("wanted error for %q", text)
+		}
+	}
+}
+
+func TestParsePathPatterns(t *testing.T) {
+	r := strings.NewReader("# junk\n\n._*\n  .Trashes/  \n!keep.mp3\n")
+	patterns, err := parsePathPatterns("a", r)
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	want := "[{a [._*] false false false} {a [.Trashes] false true false} {a [keep.mp3] true false false}]"
+	if got := fmt.Sprint(patterns); want != got {
+		t.Errorf("patterns not equal: \n wanted: %v \n got:    %v", want, got)
+	}
+	if _, err := parsePathPatterns("", strings.NewReader("ok\n[bad\n")); err == nil {
+		t.Errorf("wanted error for bad pattern")
+	}
+}
+
+func TestMatchPathPatterns(t *testing.T) {
+	var patterns []pathPattern
+	for _, text := range []string{"*.mp3", "!keep*.mp3", "keep-not.mp3"} {
+		pp, err := newPathPattern("", text)
+		if err != nil {
+			t.Fatalf("unwanted error: %v", err)
+		}
+		patterns = append(patterns, pp)
+	}
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"a.mp3", true},
+		{"a.flac", false},
+		{"keep.mp3", false},
+		{"keep-not.mp3", true},
+	}
+	for _, test := range tests {
+		if got := matchPathPatterns(patterns, test.path, false); test.want != got {
+			t.Errorf("wanted %q to match: %v, got %v", test.path, test.want, got)
+		}
+	}
+}
+
+func TestPathFilterIncluded(t *testing.T) {
+	tests := []struct {
+		name     string
+		includes []string
+		path     string
+		want     bool
+	}{
+		{"no includes", nil, "a/b.mp3", true},
+		{"file", []string{"*.flac"}, "a/b.flac", true},
+		{"file not matched", []string{"*.flac"}, "a/b.mp3", false},
+		{"folder", []string{"rock"}, "rock/live/b.mp3", true},
+		{"anchored folder", []string{"/rock/"}, "a/rock/b.mp3", false},
+		{"double star", []string{"rock/**"}, "rock/live/b.mp3", true},
+		{"any", []string{"jazz", "rock"}, "rock/b.mp3", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f, err := newPathFilter(test.includes, nil)
+			if err != nil {
+				t.Fatalf("unwanted error: %v", err)
+			}
+			if got := f.included(test.path); test.want != got {
+				t.Errorf("wanted %q to be included: %v, got %v", test.path, test.want, got)
+			}
+		})
+	}
+	if _, err := newPathFilter(nil, []string{"[bad"}); err == nil {
+		t.Errorf("wanted error for bad exclude pattern")
+	}
+}
+
+func TestPatternsFlag(t *testing.T) {
+	var f patternsFlag
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.Var(&f, "exclude", "")
+	if err := fs.Parse([]string{"-exclude", "._*", "-exclude", ".Trashes/"}); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	if want, got := "[._* .Trashes/]", fmt.Sprint(f); want != got {
+		t.Errorf("patterns not equal: wanted %v, got %v", want, got)
+	}
+	if err := fs.Parse([]string{"-exclude", ""}); err == nil {
+		t.Errorf("wanted error for empty pattern")
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultPathTemplates infer song metadata from common folder and file names, most specific first
+var defaultPathTemplates = []string{
+	"{artist}/{album}/{disc}-{track} - {title}",
+	"{artist}/{album}/{track} - {title}",
+	"{artist}/{album}/{track}. {title}",
+	"{artist}/{album}/{title}",
+	"{artist} - {title}",
+	"{title}",
+}
+
+// pathTemplateFields set empty song fields from the text of the template placeholders, by name.
+// Fields that were read from tags are kept.
+var pathTemplateFields = map[string]func(s *song, value string){
+	"artist":      func(s *song, value string) { setEmptyText(&s.artist, value) },
+	"album":       func(s *song, value string) { setEmptyText(&s.album, value) },
+	"title":       func(s *song, value string) { setEmptyText(&s.title, value) },
+	"albumartist": func(s *song, value string) { setEmptyText(&s.albumArtist, value) },
+	"genre":       func(s *song, value string) { setEmptyText(&s.genre, value) },
+	"track":       func(s *song, value string) { setEmptyNumber(&s.track, value) },
+	"disc":        func(s *song, value string) { setEmptyNumber(&s.disc, value) },
+	"year":        func(s *song, value string) { setEmptyNumber(&s.year, value) },
+}
+
+// setEmptyText sets the field to the value if the field is empty
+func setEmptyText(field *string, value string) {
+	if len(*field) == 0 {
+		*field = value
+	}
+}
+
+// setEmptyNumber sets the field to the number of the value if the field is zero
+func setEmptyNumber(field *int, value string) {
+	if *field == 0 {
+		*field, _ = strconv.Atoi(value)
+	}
+}
+
+// pathTemplateNumbers are the placeholders that only match numbers
+var pathTemplateNumbers = map[string]bool{
+	"track": true,
+	"disc":  true,
+	"year":  true,
+}
+
+// pathTemplatePlaceholder matches placeholders in templates, such as {artist}
+var pathTemplatePlaceholder = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// pathTemplate infers song metadata from paths that match a pattern, such as {artist}/{album}/{track} - {title}.
+// Templates match the end of paths without their extensions, so they can be used for songs in any folder.
+type pathTemplate struct {
+	text   string
+	re     *regexp.Regexp
+	fields []string // the names of the placeholders, in order
+}
+
+// newPathTemplate parses the template text.
+// The extension of the template is ignored.
+func newPathTemplate(text string) (*pathTemplate, error) {
+	pattern := text
+	if ext := path.Ext(text); !strings.ContainsAny(ext, "{}") {
+		pattern = strings.TrimSuffix(text, ext)
+	}
+	var sb strings.Builder
+	sb.WriteString(`^(?:.*/)?`)
+	var fields []string
+	last := 0
+	for _, m := range pathTemplatePlaceholder.FindAllStringSubmatchIndex(pattern, -1) {
+		name := strings.ToLower(pattern[m[2]:m[3]])
+		if _, ok := pathTemplateFields[name]; !ok {
+			return nil, fmt.Errorf("unknown placeholder {%v} in path template %q (wanted one of %v)", name, text, pathTemplateFieldNames())
+		}
+		sb.WriteString(regexp.QuoteMeta(pattern[last:m[0]]))
+		switch {
+		case pathTemplateNumbers[name]:
+			sb.WriteString(`(\d+)`)
+		default:
+			sb.WriteString(`([^/]+?)`)
+		}
+		fields = append(fields, name)
+		last = m[1]
+	}
+	sb.WriteString(regexp.QuoteMeta(pattern[last:]))
+	sb.WriteString(`$`)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("path template %q has no placeholders", text)
+	}
+	re, err := regexp.Compile(sb.String())
+	if err != nil {
+		return nil, fmt.Errorf("parsing path template %q: %v", text, err)
+	}
+	pt := pathTemplate{
+		text:   text,
+		re:     re,
+		fields: fields,
+	}
+	return &pt, nil
+}
+
+// newPathTemplates parses the template texts, in order
+func newPathTemplates(texts []string) ([]pathTemplate, error) {
+	templates := make([]pathTemplate, 0, len(texts))
+	for _, text := range texts {
+		pt, err := newPathTemplate(text)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, *pt)
+	}
+	return templates, nil
+}
+
+// pathTemplateFieldNames lists the names of the placeholders of path templates
+func pathTemplateFieldNames() string {
+	names := make([]string, 0, len(pathTemplateFields))
+	for name := range pathTemplateFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// infer sets the empty fields of the song from its path, returning false if the template does not match the path
+func (pt pathTemplate) infer(s *song) bool {
+	songPath := strings.TrimSuffix(s.path, path.Ext(s.path))
+	m := pt.re.FindStringSubmatch(songPath)
+	if m == nil {
+		return false
+	}
+	for i, name := range pt.fields {
+		value := strings.TrimSpace(m[i+1])
+		pathTemplateFields[name](s, value)
+	}
+	return true
+}
+
+// inferSongFields sets the empty fields of the song, such as the artist and title, from the first template that matches its path.
+// The song is marked as inferred if it matches a template.
+func inferSongFields(s *song, templates []pathTemplate) {
+	for _, pt := range templates {
+		if pt.infer(s) {
+			s.inferred = true
+			return
+		}
+	}
+}
+
+// inferredMarker is added to the titles of songs with inferred metadata in listings
+const inferredMarker = " *"
+
+// listedTitle is the title of the song in listings, marked if it was inferred
+func listedTitle(s song) string {
+	if s.inferred {
+		return s.title + inferredMarker
+	}
+	return s.title
+}
+
+// printInferredNote explains the marker if any of the songs has inferred metadata
+func printInferredNote(w io.Writer, songs []song) {
+	for _, s := range songs {
+		if s.inferred {
+			fmt.Fprintf(w, "%v: tags inferred from file path\n", strings.TrimSpace(inferredMarker))
+			return
+		}
+	}
+}
+
+// pathTemplatesFlag collects the path templates from repeated flags.
+// The default templates are used if the flag is not set.
+type pathTemplatesFlag struct {
+	values []string
+	set    bool
+}
+
+// String lists the templates
+func (f *pathTemplatesFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return strings.Join(f.values, ", ")
+}
+
+// Set adds a template, ignoring empty values
+func (f *pathTemplatesFlag) Set(value string) error {
+	f.set = true
+	if len(value) != 0 {
+		f.values = append(f.values, value)
+	}
+	return nil
+}
+
+// texts are the templates to use
+func (f pathTemplatesFlag) texts() []string {
+	if !f.set {
+		return defaultPathTemplates
+	}
+	return f.values
+}
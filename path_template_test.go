@@ -0,0 +1,149 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"testing"
+)
+
+func mustPathTemplate(t *testing.T, text string) *pathTemplate {
+	t.Helper()
+	pt, err := newPathTemplate(text)
+	if err != nil {
+		t.Fatalf("creating path template: %v", err)
+	}
+	return pt
+}
+
+func TestNewPathTemplate(t *testing.T) {
+	tests := []struct {
+		text    string
+		wantErr bool
+	}{
+		{"{artist}/{album}/{track} - {title}.mp3", false},
+		{"{Artist} - {TITLE}", false},
+		{"{track}. {title}", false},
+		{"{year} {albumartist}/{disc}/{genre} {title}", false},
+		{"{rating}/{title}", true},
+		{"songs/title.mp3", true},
+		{"", true},
+	}
+	if _, err := newPathTemplates(defaultPathTemplates); err != nil {
+		t.Errorf("creating default path templates: %v", err)
+	}
+	for _, test := range tests {
+		_, err := newPathTemplate(test.text)
+		if gotErr := err != nil; test.wantErr != gotErr {
+			t.Errorf("wanted error creating path template %q: %v, got %v", test.text, test.wantErr, err)
+		}
+	}
+}
+
+func TestPathTemplateInfer(t *testing.T) {
+	tests := []struct {
+		template string
+		path     string
+		want     song
+		wantOk   bool
+	}{
+		{
+			template: "{artist}/{album}/{track} - {title}.mp3",
+			path:     "music/Beck/Guero/04 - Missing.mp3",
+			want:     song{artist: "Beck", album: "Guero", track: 4, title: "Missing"},
+			wantOk:   true,
+		},
+		{
+			template: "{artist}/{album}/{track} - {title}",
+			path:     "Beck/Guero/Missing.mp3",
+		},
+		{
+			template: "{artist}/{album}/{track} - {title}",
+			path:     "Beck/Guero/four - Missing.mp3",
+		},
+		{
+			template: "{artist} - {title}",
+			path:     "The Killers - Mr. Brightside.m4a",
+			want:     song{artist: "The Killers", title: "Mr. Brightside"},
+			wantOk:   true,
+		},
+		{
+			template: "{track}. {title}",
+			path:     "a/07. On Top.mp3",
+			want:     song{track: 7, title: "On Top"},
+			wantOk:   true,
+		},
+		{
+			template: "{year} - {album}/{disc}-{track} {title}",
+			path:     "2004 - Hot Fuss/1-02 Mr. Brightside.mp3",
+			want:     song{year: 2004, album: "Hot Fuss", disc: 1, track: 2, title: "Mr. Brightside"},
+			wantOk:   true,
+		},
+		{
+			template: "{title}",
+			path:     "a/b/c.mp3",
+			want:     song{title: "c"},
+			wantOk:   true,
+		},
+	}
+	for i, test := range tests {
+		pt := mustPathTemplate(t, test.template)
+		s := song{path: test.path}
+		test.want.path = test.path
+		gotOk := pt.infer(&s)
+		switch {
+		case test.wantOk != gotOk:
+			t.Errorf("test %v: wanted %q to match %q: %v", i, test.template, test.path, test.wantOk)
+		case gotOk && test.want != s:
+			t.Errorf("test %v: inferred songs not equal: \n wanted: %v \n got:    %v", i, test.want, s)
+		}
+	}
+}
+
+func TestInferSongFields(t *testing.T) {
+	templates := []pathTemplate{
+		*mustPathTemplate(t, "{artist}/{album}/{title}"),
+		*mustPathTemplate(t, "{artist} - {title}"),
+	}
+	tests := []struct {
+		path string
+		song song
+		want song
+	}{
+		{"a/b/c.mp3", song{}, song{artist: "a", album: "b", title: "c", inferred: true}},
+		{"a - b.mp3", song{}, song{artist: "a", title: "b", inferred: true}},
+		{"ab.mp3", song{}, song{}},
+		{"a/b/c.mp3", song{album: "tagged", track: 4}, song{artist: "a", album: "tagged", title: "c", track: 4, inferred: true}},
+	}
+	for _, test := range tests {
+		s := test.song
+		s.path = test.path
+		test.want.path = test.path
+		inferSongFields(&s, templates)
+		if test.want != s {
+			t.Errorf("inferred songs not equal for %q: \n wanted: %v \n got:    %v", test.path, test.want, s)
+		}
+	}
+}
+
+func TestPathTemplatesFlag(t *testing.T) {
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{nil, defaultPathTemplates},
+		{[]string{"-template", "{artist}/{title}", "-template", "{title}"}, []string{"{artist}/{title}", "{title}"}},
+		{[]string{"-template", ""}, nil},
+	}
+	for _, test := range tests {
+		var f pathTemplatesFlag
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.Var(&f, "template", "")
+		if err := fs.Parse(test.args); err != nil {
+			t.Errorf("parsing %v: %v", test.args, err)
+			continue
+		}
+		if want, got := fmt.Sprint(test.want), fmt.Sprint(f.texts()); want != got {
+			t.Errorf("templates for %v not equal: wanted %v, got %v", test.args, want, got)
+		}
+	}
+}
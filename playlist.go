@@ -5,48 +5,87 @@ import (
 	"fmt"
 	"io"
 	"io/fs"
+	"path"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
+	"unicode"
 )
 
 type playlistFS interface {
 	fs.FS
 	CreateFile(name string) (io.WriteCloser, error)
+	ReplaceFile(name string) (io.WriteCloser, error)
+}
+
+// aborter is implemented by files that can discard what was written to them instead of saving it
+type aborter interface {
+	Abort() error
 }
 
 type playlist struct {
-	songs     []song
-	selection []song
-	tracks    []m3uTrack
-	fsys      playlistFS
-	w         io.Writer
-	showHash  bool
+	songs          []song
+	selection      []song
+	scores         []int // the scores of the selected songs if they were filtered with a fuzzy filter
+	tracks         []m3uTrack
+	fsys           playlistFS
+	w              io.Writer
+	showHash       bool
+	root           string        // the absolute, slash-separated path of the working directory
+	libraryRoots   []libraryRoot // the folders songs are read from
+	history        playlistHistory
+	dirty          bool                                           // true if the tracks have changed since they were loaded or written
+	path           string                                         // the playlist file that was last loaded or written
+	moved          map[string]song                                // songs that were removed from the library since it was last read, by path
+	columns        []string                                       // the names of the optional song columns to display in tables
+	loadErrors     []loadError                                    // the problems with files when the songs were loaded
+	readSongs      func(w io.Writer) ([]song, []loadError, error) // reads the songs in the library again
+	confirm        func(question string) bool                     // asks the user a yes/no question
+	fuzzyThreshold int                                            // the minimum score of songs that match fuzzy filters
+	failed         bool                                           // true if a command failed since it was last reset, which stops scripts
 }
 
 type m3uTrack struct {
 	song
 	display string
+	missing bool // the file of the song was not found when the library was rescanned
 }
 
 func newPlaylist(songs []song, fsys playlistFS, w io.Writer, showHash bool) *playlist {
 	p := playlist{
-		songs:     make([]song, len(songs)),
-		selection: make([]song, 0, len(songs)),
-		fsys:      fsys,
-		w:         w,
-		showHash:  showHash,
+		songs:          make([]song, len(songs)),
+		selection:      make([]song, 0, len(songs)),
+		fsys:           fsys,
+		w:              w,
+		showHash:       showHash,
+		fuzzyThreshold: defaultFuzzyThreshold,
 	}
 	copy(p.songs, songs)
 	sort.Slice(p.songs, songLess(p.songs))
 	return &p
 }
 
+// fail writes the error message of a command that failed, recording the failure
+func (p *playlist) fail(format string, a ...interface{}) {
+	p.failed = true
+	fmt.Fprintf(p.w, format, a...)
+}
+
 // filter limits the songs to be displayed and selected
 func (p *playlist) filter(command string) {
+	q, err := parseQuery(command, p.showHash)
+	if err != nil {
+		p.fail("Error (filter): invalid query: %v\n", err)
+		if qe, ok := err.(queryError); ok {
+			fmt.Fprintf(p.w, "    %v\n    %v\n", command, qe.marker())
+		}
+		return
+	}
 	p.selection = p.selection[:0]
+	p.scores = nil
 	for _, s := range p.songs {
-		if s.matches(command, p.showHash) {
+		if q.matches(s) {
 			p.selection = append(p.selection, s)
 		}
 	}
@@ -70,52 +109,156 @@ func (p *playlist) printSongFilter(_ string) {
 	}
 	maxArtistWidth := maxWidth(6, func(s song) int { return len(s.artist) })
 	maxAlbumWidth := maxWidth(5, func(s song) int { return len(s.album) })
-	hashFormat := "%32v    "
-	format := fmt.Sprintf("%%%dv    %%-%dv    %%-%dv    %%v\n", maxIDWidth, maxArtistWidth, maxAlbumWidth)
+	maxLengthWidth := maxWidth(6, func(s song) int { return len(formatDuration(s.duration)) })
+	hashFormat := hashColumnFormat(p.selection)
+	format := fmt.Sprintf("%%%dv    %%-%dv    %%-%dv    %v%%%dv    %%v\n", maxIDWidth, maxArtistWidth, maxAlbumWidth, p.columnsFormat(p.selection), maxLengthWidth)
+	header := func(c songColumn) string { return c.header }
+	scored := p.scores != nil
+	const scoreFormat = "%5v    "
+	if scored {
+		fmt.Fprintf(p.w, scoreFormat, "Score")
+	}
 	if p.showHash {
 		fmt.Fprintf(p.w, hashFormat, "Hash")
 	}
-	fmt.Fprintf(p.w, format, "ID", "Artist", "Album", "Title")
+	fmt.Fprintf(p.w, format, p.columnRow(header, []interface{}{"ID", "Artist", "Album"}, "Length", "Title")...)
 	for i, s := range p.selection {
+		if scored {
+			fmt.Fprintf(p.w, scoreFormat, p.scores[i])
+		}
 		if p.showHash {
 			fmt.Fprintf(p.w, hashFormat, s.hash)
 		}
 		id := i + 1
-		fmt.Fprintf(p.w, format, id, s.artist, s.album, s.title)
+		value := func(c songColumn) string { return c.value(s) }
+		fmt.Fprintf(p.w, format, p.columnRow(value, []interface{}{id, s.artist, s.album}, formatDuration(s.duration), listedTitle(s))...)
 	}
+	printInferredNote(p.w, p.selection)
 }
 
-// addTrack adds a song from the last filter to the playlist by id
-func (p *playlist) addTrack(filterID string) {
+// addTrack adds songs from the last filter to the playlist by id.
+// Ids are lists and ranges, such as 1-5,8,12-, or * for the whole selection.
+// The songs are added to the end of the playlist unless a position is given, such as 3-7 @ 2.
+func (p *playlist) addTrack(command string) {
 	if len(p.selection) == 0 {
-		fmt.Fprintf(p.w, "Error (add track): no selection\n")
+		p.fail("Error (add track): no selection\n")
 		return
 	}
-	id, err := strconv.Atoi(filterID)
-	if err != nil || id <= 0 || id > len(p.selection) {
-		fmt.Fprintf(p.w, "Error (add track): reading song id %q from selection. Must be in (1-%v): %v\n", filterID, len(p.selection), err)
+	filterIDs, position, hasPosition := command, "", false
+	if atIndex := strings.Index(command, "@"); atIndex >= 0 {
+		filterIDs, position, hasPosition = command[:atIndex], strings.TrimSpace(command[atIndex+1:]), true
+	}
+	ids, err := parseIDRanges(filterIDs, len(p.selection))
+	if err != nil {
+		p.fail("Error (add track): reading song ids %q from selection: %v\n", filterIDs, err)
 		return
 	}
-	s := p.selection[id-1]
-	t := m3uTrack{
-		song:    s,
-		display: s.display(),
+	index := len(p.tracks)
+	if hasPosition {
+		pos, err := strconv.Atoi(position)
+		if err != nil || pos <= 0 || pos > len(p.tracks)+1 {
+			p.fail("Error (add track): reading track position %q. Must be in (1-%v): %v\n", position, len(p.tracks)+1, err)
+			return
+		}
+		index = pos - 1 // make 1-indexed
+	}
+	tracks := make([]m3uTrack, len(ids))
+	for i, id := range ids {
+		s := p.selection[id-1]
+		tracks[i] = m3uTrack{
+			song:    s,
+			display: s.display(),
+		}
+	}
+	paths := make(map[string]struct{}, len(p.tracks))
+	for _, t := range p.tracks {
+		paths[t.path] = struct{}{}
+	}
+	repeated := 0
+	for _, t := range tracks {
+		if _, ok := paths[t.path]; ok && len(t.path) != 0 {
+			repeated++
+		}
+		paths[t.path] = struct{}{} // songs can be repeated in the ids, such as a 3,3
 	}
-	p.tracks = append(p.tracks, t)
+	if repeated != 0 {
+		fmt.Fprintf(p.w, "%v of the songs were already in the playlist or added more than once, use dedupe to remove duplicate tracks\n", repeated)
+	}
+	if len(tracks) == 1 {
+		p.record(fmt.Sprintf("add track %q", tracks[0].display))
+	} else {
+		p.record(fmt.Sprintf("add %v tracks", len(tracks)))
+	}
+	p.tracks = append(p.tracks, tracks...)
+	copy(p.tracks[index+len(tracks):], p.tracks[index:])
+	copy(p.tracks[index:], tracks)
+}
+
+// parseIDRanges reads the comma or space separated ids and ranges of ids, which must be in 1-maxID.
+// Ranges without an end, such as 12-, end at maxID and * is every id.
+// All of the invalid ids are included in the error.
+func parseIDRanges(text string, maxID int) ([]int, error) {
+	parts := strings.FieldsFunc(text, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("no ids")
+	}
+	var ids []int
+	var invalid []string
+	parseID := func(s string) (int, bool) {
+		id, err := strconv.Atoi(s)
+		return id, err == nil && id > 0 && id <= maxID
+	}
+	for _, part := range parts {
+		if part == "*" {
+			for id := 1; id <= maxID; id++ {
+				ids = append(ids, id)
+			}
+			continue
+		}
+		dashIndex := strings.Index(part, "-")
+		if dashIndex < 0 {
+			id, ok := parseID(part)
+			if !ok {
+				invalid = append(invalid, part)
+				continue
+			}
+			ids = append(ids, id)
+			continue
+		}
+		first, ok1 := parseID(part[:dashIndex])
+		last, ok2 := maxID, true
+		if dashIndex+1 < len(part) {
+			last, ok2 = parseID(part[dashIndex+1:])
+		}
+		if !ok1 || !ok2 || last < first {
+			invalid = append(invalid, part)
+			continue
+		}
+		for id := first; id <= last; id++ {
+			ids = append(ids, id)
+		}
+	}
+	if len(invalid) != 0 {
+		return nil, fmt.Errorf("invalid ids (must be in 1-%v): %v", maxID, strings.Join(invalid, ", "))
+	}
+	return ids, nil
 }
 
 // removeTrack removes a song from the playlist by id
 func (p *playlist) removeTrack(idx string) {
 	if len(p.tracks) == 0 {
-		fmt.Fprintf(p.w, "Error (remove track): no selection\n")
+		p.fail("Error (remove track): no selection\n")
 		return
 	}
 	id, err := strconv.Atoi(idx)
 	if err != nil || id <= 0 || id > len(p.tracks) {
-		fmt.Fprintf(p.w, "Error (remove track): reading track index %q from playlist. Must be in (1-%v): %v\n", idx, len(p.tracks), err)
+		p.fail("Error (remove track): reading track index %q from playlist. Must be in (1-%v): %v\n", idx, len(p.tracks), err)
 		return
 	}
 	id-- // make 1-indexed
+	p.record(fmt.Sprintf("remove track %v %q", idx, p.tracks[id].display))
 	copy(p.tracks[id:], p.tracks[id+1:])
 	p.tracks = p.tracks[:len(p.tracks)-1]
 }
@@ -124,22 +267,25 @@ func (p *playlist) removeTrack(idx string) {
 func (p *playlist) moveTrack(command string) {
 	f := strings.Fields(command)
 	if len(f) != 2 {
-		fmt.Fprintf(p.w, "Error (move track): wanted track id and move index\n")
+		p.fail("Error (move track): wanted track id and move index\n")
 		return
 	}
 	trackIdx, moveIndex := f[0], f[1]
 	id, err := strconv.Atoi(trackIdx)
 	if err != nil || id <= 0 || id > len(p.tracks) {
-		fmt.Fprintf(p.w, "Error (move track): reading track index %q from playlist. Must be in (1-%v): %v\n", trackIdx, len(p.tracks), err)
+		p.fail("Error (move track): reading track index %q from playlist. Must be in (1-%v): %v\n", trackIdx, len(p.tracks), err)
 		return
 	}
 	id-- // make 1-indexed
 	destIdx, err := strconv.Atoi(moveIndex)
 	if err != nil || destIdx <= 0 || destIdx > len(p.tracks) {
-		fmt.Fprintf(p.w, "Error (move track): reading track destination index %q from playlist. Must be in (1-%v): %v\n", destIdx, len(p.tracks), err)
+		p.fail("Error (move track): reading track destination index %q from playlist. Must be in (1-%v): %v\n", destIdx, len(p.tracks), err)
 		return
 	}
 	destIdx-- // make 1-indexed
+	if id != destIdx {
+		p.record(fmt.Sprintf("move track %v to %v", trackIdx, moveIndex))
+	}
 	switch {
 	case id == destIdx:
 		// NOOP
@@ -158,18 +304,19 @@ func (p *playlist) moveTrack(command string) {
 func (p *playlist) renameTrack(command string) {
 	f := strings.Fields(command)
 	if len(f) < 2 {
-		fmt.Fprintf(p.w, "Error (rename track): wanted track id and move index\n")
+		p.fail("Error (rename track): wanted track id and move index\n")
 		return
 	}
 	trackIdx := f[0]
 	id, err := strconv.Atoi(trackIdx)
 	if err != nil || id <= 0 || id > len(p.tracks) {
-		fmt.Fprintf(p.w, "Error (rename track): reading track id %q from playlist. Must be in (1-%v): %v\n", trackIdx, len(p.tracks), err)
+		p.fail("Error (rename track): reading track id %q from playlist. Must be in (1-%v): %v\n", trackIdx, len(p.tracks), err)
 		return
 	}
 	id-- // make 1-indexed
 	displayStart := strings.Index(command, f[1])
 	display := command[displayStart:]
+	p.record(fmt.Sprintf("rename track %v from %q to %q", trackIdx, p.tracks[id].display, display))
 	p.tracks[id].display = display
 }
 
@@ -188,52 +335,82 @@ func (p *playlist) printTracks(_ string) {
 	if maxIDWidth < 5 {
 		maxIDWidth = 5
 	}
-	maxDisplayWidth := maxWidth(7, func(t m3uTrack) int { return len(t.display) })
+	maxDisplayWidth := maxWidth(7, func(t m3uTrack) int { return len(t.listedDisplay()) })
 	maxArtistWidth := maxWidth(6, func(t m3uTrack) int { return len(t.artist) })
 	maxAlbumWidth := maxWidth(5, func(t m3uTrack) int { return len(t.album) })
-	hashFormat := "%32v    "
-	format := fmt.Sprintf("%%%dv    %%-%dv    %%-%dv    %%-%dv    %%v\n", maxIDWidth, maxDisplayWidth, maxArtistWidth, maxAlbumWidth)
+	maxLengthWidth := maxWidth(6, func(t m3uTrack) int { return len(formatDuration(t.duration)) })
+	songs := make([]song, len(p.tracks))
+	for i, t := range p.tracks {
+		songs[i] = t.song
+	}
+	hashFormat := hashColumnFormat(songs)
+	format := fmt.Sprintf("%%%dv    %%-%dv    %%-%dv    %%-%dv    %v%%%dv    %%v\n", maxIDWidth, maxDisplayWidth, maxArtistWidth, maxAlbumWidth, p.columnsFormat(songs), maxLengthWidth)
+	header := func(c songColumn) string { return c.header }
 	if p.showHash {
 		fmt.Fprintf(p.w, hashFormat, "Hash")
 	}
-	fmt.Fprintf(p.w, format, "Index", "Display", "Artist", "Album", "Title")
+	fmt.Fprintf(p.w, format, p.columnRow(header, []interface{}{"Index", "Display", "Artist", "Album"}, "Length", "Title")...)
 	for i, t := range p.tracks {
 		if p.showHash {
 			fmt.Fprintf(p.w, hashFormat, t.hash)
 		}
 		idx := i + 1
-		fmt.Fprintf(p.w, format, idx, t.display, t.artist, t.album, t.title)
+		value := func(c songColumn) string { return c.value(t.song) }
+		fmt.Fprintf(p.w, format, p.columnRow(value, []interface{}{idx, t.listedDisplay(), t.artist, t.album}, formatDuration(t.duration), listedTitle(t.song))...)
+	}
+	printInferredNote(p.w, songs)
+	var total time.Duration
+	for _, t := range p.tracks {
+		total += t.duration
+	}
+	if total > 0 {
+		fmt.Fprintf(p.w, "total length: %v\n", formatDuration(total))
 	}
 }
 
 // clearTracks removes the tracks from the playlist
 func (p *playlist) clearTracks(_ string) {
+	if len(p.tracks) == 0 {
+		return
+	}
+	if !p.confirmDiscard("Clear tracks") {
+		return
+	}
+	p.record(fmt.Sprintf("clear %v tracks", len(p.tracks)))
 	p.tracks = nil
 }
 
 // load imports a playlist by name
-func (p *playlist) load(m3uPath string) {
-	f, err := p.fsys.Open(m3uPath)
+func (p *playlist) load(playlistPath string) {
+	if !p.confirmDiscard("Load playlist") {
+		return
+	}
+	pf, ok := playlistFormatOf(playlistPath)
+	if !ok {
+		pf = playlistFormats[0] // try to read unknown files as m3u
+	}
+	f, err := p.fsys.Open(playlistPath)
 	if err != nil {
-		fmt.Fprintf(p.w, "Error (load playlist): loading playlist file: %v\n", err)
+		p.fail("Error (load playlist): loading playlist file: %v\n", err)
 		return
 	}
-	if _, err := p.ReadFrom(f); err != nil {
-		fmt.Fprintf(p.w, "Error (load playlist): %v\n", err)
+	defer f.Close()
+	p.recordLoad(fmt.Sprintf("load %v", playlistPath))
+	p.path = playlistPath
+	_, err = pf.read(p, f)
+	p.dirty = false // missing tracks are kept, so the tracks match the file until they are changed, such as by relink
+	if err != nil {
+		p.fail("Error (load playlist): %v\n", err)
+	}
+	if missing := p.missingTracks(); missing != 0 {
+		fmt.Fprintf(p.w, "%v tracks are missing, use relink to find the songs if they were moved\n", missing)
 	}
 }
 
 // ReadFrom reads the playlist tracks from the reader, updating the playlist contain all valid songs in the file
 func (p *playlist) ReadFrom(r io.Reader) (n int64, err error) {
-	songPaths := make(map[string]song, len(p.songs))
-	for _, s := range p.songs {
-		songPaths[s.path] = s
-	}
 	s := bufio.NewScanner(r)
-	var tracks []m3uTrack
-	var t m3uTrack
-	var errors []string
-	const maxErrors = 10
+	tr := p.newTrackResolver()
 	display := ""
 	for s.Scan() {
 		line := s.Text()
@@ -251,26 +428,81 @@ func (p *playlist) ReadFrom(r io.Reader) (n int64, err error) {
 			}
 		default:
 			// treat line as path
-			t, err = getTrack(line, songPaths, display)
-			switch {
-			case err == nil:
-				tracks = append(tracks, t)
-			case len(errors) < maxErrors:
-				errors = append(errors, fmt.Sprintf("song not found: %q", line))
-			case len(errors) == maxErrors:
-				errors = append(errors, "... additional song load errors not displayed")
-			}
+			tr.add(tr.songPath(line), display)
 			display = ""
 		}
 	}
+	p.tracks = tr.tracks
+	if err := s.Err(); err != nil {
+		return n, fmt.Errorf("reading playlist file: %v", err)
+	}
+	return n, tr.err()
+}
+
+// trackResolver creates tracks for the song paths in a playlist file, collecting errors for paths that are not songs
+type trackResolver struct {
+	dir       string // the folder of the playlist file
+	songPaths map[string]song
+	tracks    []m3uTrack
+	errors    []string
+}
+
+func (p *playlist) newTrackResolver() *trackResolver {
+	songPaths := make(map[string]song, len(p.songs))
+	for _, s := range p.songs {
+		songPaths[s.path] = s
+	}
+	tr := trackResolver{
+		dir:       path.Dir(p.path),
+		songPaths: songPaths,
+	}
+	return &tr
+}
+
+// songPath resolves the path of the song of an entry relative to the folder of the playlist file.
+// Entries relative to the library root are also resolved for playlists written before entries were relative to the playlist file.
+func (tr *trackResolver) songPath(entry string) string {
+	if tr.dir == "." || len(entry) == 0 || isAbsolutePath(entry) {
+		return entry
+	}
+	songPath := path.Join(tr.dir, entry)
+	if _, ok := tr.songPaths[songPath]; !ok {
+		if _, ok := tr.songPaths[entry]; ok {
+			return entry
+		}
+	}
+	return songPath
+}
+
+// add appends the track of the song at the path.
+// If the song is not found, a missing track with the path is added in its place and an error is recorded.
+func (tr *trackResolver) add(path, display string) {
+	const maxErrors = 10
+	t, err := getTrack(path, tr.songPaths, display)
+	if err == nil {
+		tr.tracks = append(tr.tracks, t)
+		return
+	}
+	m := m3uTrack{
+		song:    song{path: path},
+		display: display,
+		missing: true,
+	}
+	tr.tracks = append(tr.tracks, m)
 	switch {
-	case s.Err() != nil:
-		err = fmt.Errorf("reading playlist file: %v", err)
-	case len(errors) != 0:
-		err = fmt.Errorf("loading playlist songs:\n%v", strings.Join(errors, "\n"))
+	case len(tr.errors) < maxErrors:
+		tr.errors = append(tr.errors, err.Error())
+	case len(tr.errors) == maxErrors:
+		tr.errors = append(tr.errors, "... additional song load errors not displayed")
 	}
-	p.tracks = tracks
-	return
+}
+
+// err combines the errors of paths that were not songs
+func (tr *trackResolver) err() error {
+	if len(tr.errors) == 0 {
+		return nil
+	}
+	return fmt.Errorf("loading playlist songs:\n%v", strings.Join(tr.errors, "\n"))
 }
 
 func getTrack(line string, songPaths map[string]song, display string) (t m3uTrack, err error) {
@@ -290,25 +522,58 @@ func getTrack(line string, songPaths map[string]song, display string) (t m3uTrac
 }
 
 // write exports the playlist to a new file by name
-func (p *playlist) write(m3uPath string) {
-	if !strings.HasSuffix(m3uPath, ".m3u") || len(m3uPath) == 4 {
-		fmt.Fprintf(p.w, "Error (write playlist): path must end with .m3u, got %q\n", m3uPath)
+func (p *playlist) write(playlistPath string) {
+	p.writeFile(playlistPath, false)
+}
+
+// overwrite exports the playlist to a file by name, replacing the file if it exists
+func (p *playlist) overwrite(playlistPath string) {
+	p.writeFile(playlistPath, true)
+}
+
+// save exports the playlist to the file it was last loaded from or written to, replacing the file
+func (p *playlist) save(_ string) {
+	if len(p.path) == 0 {
+		p.fail("Error (save playlist): no playlist has been loaded or written, use w <filename>\n")
+		return
+	}
+	p.writeFile(p.path, true)
+}
+
+// writeFile exports the playlist to the file by name.
+// If replace is true, existing files are safely replaced, otherwise only new files are written.
+func (p *playlist) writeFile(playlistPath string, replace bool) {
+	pf, ok := playlistFormatOf(playlistPath)
+	if !ok {
+		p.fail("Error (write playlist): path must end with one of %v, got %q\n", playlistExtensions(), playlistPath)
 		return
 	}
-	f, err := p.fsys.CreateFile(m3uPath)
+	createFile := p.fsys.CreateFile
+	if replace {
+		createFile = p.fsys.ReplaceFile
+	}
+	f, err := createFile(playlistPath)
 	if err != nil {
-		fmt.Fprintf(p.w, "Error (write playlist): creating file: %v", err)
+		p.fail("Error (write playlist): creating file: %v\n", err)
 		return
 	}
-	defer func() {
-		if err := f.Close(); err != nil {
-			fmt.Fprintf(p.w, "Error (closing %q): %v", m3uPath, err)
+	wp := *p
+	wp.path = playlistPath // write paths relative to the new file
+	if _, err := pf.write(wp, f); err != nil {
+		if a, ok := f.(aborter); ok {
+			a.Abort() // keep the previous file
+		} else {
+			f.Close()
 		}
-	}()
-	if _, err := p.WriteTo(f); err != nil {
-		fmt.Fprintf(p.w, "Error (writing tracks): %v", err)
+		p.fail("Error (writing tracks): %v\n", err)
+		return
+	}
+	if err := f.Close(); err != nil {
+		p.fail("Error (closing %q): %v\n", playlistPath, err)
 		return
 	}
+	p.path = playlistPath
+	p.dirty = false
 }
 
 // WriteTo writes the tracks of the playlist
@@ -317,26 +582,83 @@ func (p playlist) WriteTo(w io.Writer) (n int64, err error) {
 	n += int64(n2)
 	for i := 0; err == nil && i < len(p.tracks); i++ {
 		t := p.tracks[i]
-		n2, err = fmt.Fprintf(w, "#EXTINF:0, %v\r\n%v\r\n", t.display, t.path)
+		n2, err = fmt.Fprintf(w, "#EXTINF:%d, %v\r\n%v\r\n", durationSeconds(t.duration), t.display, p.entryPath(t.path))
 		n += int64(n2)
 	}
 	return
 }
 
-// songLess creates a song function that compares song indices by artist, album, track, then title
+// entryPath is the path of the song relative to the folder of the playlist file, which players resolve entries from
+func (p playlist) entryPath(songPath string) string {
+	dir := path.Dir(p.path)
+	if dir == "." {
+		return songPath
+	}
+	return relativePath(dir, songPath)
+}
+
+// durationSeconds rounds the duration to the nearest second
+func durationSeconds(d time.Duration) int64 {
+	return int64(d.Round(time.Second) / time.Second)
+}
+
+// playlistFormat reads and writes playlist files with an extension
+type playlistFormat struct {
+	ext   string
+	read  func(p *playlist, r io.Reader) (int64, error)
+	write func(p playlist, w io.Writer) (int64, error)
+}
+
+// playlistFormats are the supported types of playlist files, the first is the default
+var playlistFormats = []playlistFormat{
+	{".m3u", (*playlist).ReadFrom, playlist.WriteTo},
+	{".pls", (*playlist).readPLS, playlist.writePLS},
+	{".xspf", (*playlist).readXSPF, playlist.writeXSPF},
+}
+
+// playlistFormatOf finds the format of the playlist file by its extension, ignoring case
+func playlistFormatOf(name string) (playlistFormat, bool) {
+	ext := strings.ToLower(path.Ext(name))
+	for _, pf := range playlistFormats {
+		if pf.ext == ext && len(name) > len(ext) {
+			return pf, true
+		}
+	}
+	return playlistFormat{}, false
+}
+
+// playlistExtensions lists the extensions of the playlist formats
+func playlistExtensions() string {
+	exts := make([]string, len(playlistFormats))
+	for i, pf := range playlistFormats {
+		exts[i] = pf.ext
+	}
+	return strings.Join(exts, ", ")
+}
+
+// songLess creates a song function that compares song indices by album artist (or artist), album, disc, track, then title
 func songLess(s []song) func(i, j int) bool {
 	return func(i, j int) bool {
-		if s[i].artist != s[j].artist {
-			return s[i].artist < s[j].artist
-		}
-		if s[i].album != s[j].album {
-			return s[i].album < s[j].album
-		}
-		if s[i].track != s[j].track {
-			return s[i].track < s[j].track
-		}
-		return s[i].title < s[j].title
+		return compareSongs(s[i], s[j]) < 0
+	}
+}
+
+// compareSongs orders songs by album artist (or artist), album, disc, track, then title.
+// The result is negative if a is before b, positive if b is before a, and zero if they are in the same place.
+func compareSongs(a, b song) int {
+	if a, b := a.sortArtist(), b.sortArtist(); a != b {
+		return strings.Compare(a, b)
+	}
+	if a.album != b.album {
+		return strings.Compare(a.album, b.album)
+	}
+	if a.disc != b.disc {
+		return compareInts(int64(a.disc), int64(b.disc))
+	}
+	if a.track != b.track {
+		return compareInts(int64(a.track), int64(b.track))
 	}
+	return strings.Compare(a.title, b.title)
 }
 
 // digitCount computes the number of digits in the positive number.
@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"strings"
 	"testing"
 	"testing/fstest"
 	"testing/iotest"
+	"time"
 )
 
 func TestNewPlaylist(t *testing.T) {
@@ -95,6 +97,27 @@ func TestPlaylistFilter(t *testing.T) {
 	}
 }
 
+func TestPlaylistFilterInvalidQuery(t *testing.T) {
+	p := playlist{
+		songs:     []song{{artist: "Beck"}, {artist: "Queen"}},
+		selection: []song{{artist: "Queen"}},
+	}
+	want := playlist{
+		songs:     []song{{artist: "Beck"}, {artist: "Queen"}},
+		selection: []song{{artist: "Queen"}},
+	}
+	var w bytes.Buffer
+	p.w = &w
+	p.filter("beck artst:x")
+	checkPlaylistsEqual(t, want, p)
+	wantOutput := "Error (filter): invalid query: unknown field (wanted one of album, albumartist, artist, comment, composer, disc, genre, hash, path, root, title, track, year) at position 6: \"artst:x\"\n" +
+		"    beck artst:x\n" +
+		"         ^^^^^^^\n"
+	if got := w.String(); wantOutput != got {
+		t.Errorf("outputs not equal: \n wanted: %q \n got:    %q", wantOutput, got)
+	}
+}
+
 func TestPlaylistPrintFilter(t *testing.T) {
 	tests := []struct {
 		name string
@@ -108,8 +131,8 @@ func TestPlaylistPrintFilter(t *testing.T) {
 					{artist: "x", album: "y", track: 8, title: "z"},
 				},
 			},
-			want: `ID    Artist    Album    Title
- 1    x         y        z
+			want: `ID    Artist    Album    Length    Title
+ 1    x         y             ?    z
 `,
 		},
 		{
@@ -130,19 +153,19 @@ func TestPlaylistPrintFilter(t *testing.T) {
 					{artist: "The Killers", album: "Hot Fuss", track: 11, title: "Everything Will Be Alright"},
 				},
 			},
-			want: `ID    Artist         Album       Title
- 1    Beck           Guero       Missing
- 2    The Killers    Hot Fuss    Jenny Was A Friend Of Mine
- 3    The Killers    Hot Fuss    Mr. Brightside
- 4    The Killers    Hot Fuss    Smile Like You Mean It
- 5    The Killers    Hot Fuss    Somebody Told Me
- 6    The Killers    Hot Fuss    All These Things I've Done
- 7    The Killers    Hot Fuss    Andy, You're A Star
- 8    The Killers    Hot Fuss    On Top
- 9    The Killers    Hot Fuss    Change Your Mind
-10    The Killers    Hot Fuss    Believe Me Natalie
-11    The Killers    Hot Fuss    Midnight Show
-12    The Killers    Hot Fuss    Everything Will Be Alright
+			want: `ID    Artist         Album       Length    Title
+ 1    Beck           Guero            ?    Missing
+ 2    The Killers    Hot Fuss         ?    Jenny Was A Friend Of Mine
+ 3    The Killers    Hot Fuss         ?    Mr. Brightside
+ 4    The Killers    Hot Fuss         ?    Smile Like You Mean It
+ 5    The Killers    Hot Fuss         ?    Somebody Told Me
+ 6    The Killers    Hot Fuss         ?    All These Things I've Done
+ 7    The Killers    Hot Fuss         ?    Andy, You're A Star
+ 8    The Killers    Hot Fuss         ?    On Top
+ 9    The Killers    Hot Fuss         ?    Change Your Mind
+10    The Killers    Hot Fuss         ?    Believe Me Natalie
+11    The Killers    Hot Fuss         ?    Midnight Show
+12    The Killers    Hot Fuss         ?    Everything Will Be Alright
 `,
 		},
 		{
@@ -153,8 +176,34 @@ func TestPlaylistPrintFilter(t *testing.T) {
 					{artist: "x", album: "y", track: 8, title: "z", hash: "tiny"},
 				},
 			},
-			want: `                            Hash    ID    Artist    Album    Title
-                            tiny     1    x         y        z
+			want: `                            Hash    ID    Artist    Album    Length    Title
+                            tiny     1    x         y             ?    z
+`,
+		},
+		{
+			name: "inferred tags",
+			p: playlist{
+				selection: []song{
+					{artist: "x", album: "y", title: "z", inferred: true},
+				},
+			},
+			want: `ID    Artist    Album    Length    Title
+ 1    x         y             ?    z *
+*: tags inferred from file path
+`,
+		},
+		{
+			name: "fuzzy scores",
+			p: playlist{
+				selection: []song{
+					{artist: "x", album: "y", title: "z"},
+					{artist: "x", album: "y", title: "w"},
+				},
+				scores: []int{100, 86},
+			},
+			want: `Score    ID    Artist    Album    Length    Title
+  100     1    x         y             ?    z
+   86     2    x         y             ?    w
 `,
 		},
 	}
@@ -235,6 +284,107 @@ func TestPlaylistAddTrack(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:        "ranges",
+			selectionID: "3-,1 2-2",
+			p: playlist{
+				selection: []song{{title: "a"}, {title: "b"}, {title: "c"}, {title: "d"}},
+			},
+			want: playlist{
+				selection: []song{{title: "a"}, {title: "b"}, {title: "c"}, {title: "d"}},
+				tracks: []m3uTrack{
+					{song: song{title: "c"}, display: "c"},
+					{song: song{title: "d"}, display: "d"},
+					{song: song{title: "a"}, display: "a"},
+					{song: song{title: "b"}, display: "b"},
+				},
+			},
+		},
+		{
+			name:        "all at position",
+			selectionID: "* @ 2",
+			p: playlist{
+				selection: []song{{title: "a"}, {title: "b"}},
+				tracks:    []m3uTrack{{display: "x"}, {display: "y"}},
+			},
+			want: playlist{
+				selection: []song{{title: "a"}, {title: "b"}},
+				tracks: []m3uTrack{
+					{display: "x"},
+					{song: song{title: "a"}, display: "a"},
+					{song: song{title: "b"}, display: "b"},
+					{display: "y"},
+				},
+			},
+		},
+		{
+			name:        "at end position",
+			selectionID: "1@3",
+			p: playlist{
+				selection: []song{{title: "a"}},
+				tracks:    []m3uTrack{{display: "x"}, {display: "y"}},
+			},
+			want: playlist{
+				selection: []song{{title: "a"}},
+				tracks:    []m3uTrack{{display: "x"}, {display: "y"}, {song: song{title: "a"}, display: "a"}},
+			},
+		},
+		{
+			name:        "bad position",
+			selectionID: "1 @ 4",
+			p: playlist{
+				selection: []song{{title: "a"}},
+				tracks:    []m3uTrack{{display: "x"}, {display: "y"}},
+			},
+			want: playlist{
+				selection: []song{{title: "a"}},
+				tracks:    []m3uTrack{{display: "x"}, {display: "y"}},
+			},
+			wantErr: true,
+		},
+		{
+			name:        "already in playlist",
+			selectionID: "1",
+			p: playlist{
+				selection: []song{{path: "a.mp3", title: "a"}},
+				tracks:    []m3uTrack{{song: song{path: "a.mp3", title: "a"}, display: "a"}},
+			},
+			want: playlist{
+				selection: []song{{path: "a.mp3", title: "a"}},
+				tracks: []m3uTrack{
+					{song: song{path: "a.mp3", title: "a"}, display: "a"},
+					{song: song{path: "a.mp3", title: "a"}, display: "a"},
+				},
+			},
+			wantErr: true, // note that the song was already added
+		},
+		{
+			name:        "added more than once",
+			selectionID: "1-2,2",
+			p: playlist{
+				selection: []song{{path: "a.mp3", title: "a"}, {path: "b.mp3", title: "b"}},
+			},
+			want: playlist{
+				selection: []song{{path: "a.mp3", title: "a"}, {path: "b.mp3", title: "b"}},
+				tracks: []m3uTrack{
+					{song: song{path: "a.mp3", title: "a"}, display: "a"},
+					{song: song{path: "b.mp3", title: "b"}, display: "b"},
+					{song: song{path: "b.mp3", title: "b"}, display: "b"},
+				},
+			},
+			wantErr: true, // note that the song was added twice
+		},
+		{
+			name:        "some invalid ids",
+			selectionID: "1,5",
+			p: playlist{
+				selection: []song{{title: "a"}, {title: "b"}},
+			},
+			want: playlist{
+				selection: []song{{title: "a"}, {title: "b"}},
+			},
+			wantErr: true,
+		},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
@@ -249,6 +399,46 @@ func TestPlaylistAddTrack(t *testing.T) {
 	}
 }
 
+func TestParseIDRanges(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    string
+		wantErr string
+	}{
+		{"1", "[1]", ""},
+		{"1,3", "[1 3]", ""},
+		{"1, 3 4", "[1 3 4]", ""},
+		{"2-4", "[2 3 4]", ""},
+		{"4-", "[4 5]", ""},
+		{"*", "[1 2 3 4 5]", ""},
+		{"5,1-2,5", "[5 1 2 5]", ""},
+		{"", "", "no ids"},
+		{",", "", "no ids"},
+		{"0", "", "0"},
+		{"6", "", "6"},
+		{"a", "", "a"},
+		{"-3", "", "-3"},
+		{"4-2", "", "4-2"},
+		{"1-9", "", "1-9"},
+		{"1,0,3,x-", "", "0, x-"},
+	}
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			got, err := parseIDRanges(test.text, 5)
+			switch {
+			case len(test.wantErr) != 0:
+				if err == nil || !strings.HasSuffix(err.Error(), test.wantErr) {
+					t.Errorf("wanted error ending with %q, got %v", test.wantErr, err)
+				}
+			case err != nil:
+				t.Errorf("unwanted error: %v", err)
+			case test.want != fmt.Sprint(got):
+				t.Errorf("ids not equal: wanted %v, got %v", test.want, got)
+			}
+		})
+	}
+}
+
 func TestPlaylistRemoveTrack(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -482,11 +672,12 @@ func TestPlaylistPrintTracks(t *testing.T) {
 			name: "short list",
 			p: playlist{
 				tracks: []m3uTrack{
-					{song: song{artist: "x", album: "y", track: 8, title: "z", path: "b"}, display: "a"},
+					{song: song{artist: "x", album: "y", track: 8, title: "z", path: "b", duration: 185 * time.Second}, display: "a"},
 				},
 			},
-			want: `Index    Display    Artist    Album    Title
-    1    a          x         y        z
+			want: `Index    Display    Artist    Album    Length    Title
+    1    a          x         y          3:05    z
+total length: 3:05
 `,
 		},
 		{
@@ -496,8 +687,8 @@ func TestPlaylistPrintTracks(t *testing.T) {
 					{song: song{artist: "David Bowie", album: "The Rise and Fall of Ziggy Stardust and the Spiders from Mars", track: 1, title: "Five Years"}, display: "long-title"},
 				},
 			},
-			want: `Index    Display       Artist         Album                                                            Title
-    1    long-title    David Bowie    The Rise and Fall of Ziggy Stardust and the Spiders from Mars    Five Years
+			want: `Index    Display       Artist         Album                                                            Length    Title
+    1    long-title    David Bowie    The Rise and Fall of Ziggy Stardust and the Spiders from Mars         ?    Five Years
 `,
 		},
 		{
@@ -508,8 +699,8 @@ func TestPlaylistPrintTracks(t *testing.T) {
 					{song: song{artist: "x", album: "y", track: 8, title: "z", path: "b", hash: "tiny"}, display: "a"},
 				},
 			},
-			want: `                            Hash    Index    Display    Artist    Album    Title
-                            tiny        1    a          x         y        z
+			want: `                            Hash    Index    Display    Artist    Album    Length    Title
+                            tiny        1    a          x         y             ?    z
 `,
 		},
 	}
@@ -590,6 +781,7 @@ d/h.mp3
 					{path: "d/h.mp3", track: 2},
 				},
 				tracks: []m3uTrack{
+					{song: song{path: "a/b/UNKNOWN.mp3"}, display: "Track 3 title", missing: true},
 					{song: song{path: "d/g.mp3", track: 1}, display: "Track 1 title"},
 					{song: song{path: "d/h.mp3", track: 2}, display: "Track 2 title"},
 				},
@@ -608,6 +800,22 @@ d/h.mp3
 					},
 				},
 			},
+			want: playlist{
+				tracks: []m3uTrack{
+					{song: song{path: "a/b/x"}, missing: true},
+					{song: song{path: "a/b/x"}, missing: true},
+					{song: song{path: "a/b/x"}, missing: true},
+					{song: song{path: "a/b/x"}, missing: true},
+					{song: song{path: "a/b/x"}, missing: true},
+					{song: song{path: "a/b/x"}, missing: true},
+					{song: song{path: "a/b/x"}, missing: true},
+					{song: song{path: "a/b/x"}, missing: true},
+					{song: song{path: "a/b/x"}, missing: true},
+					{song: song{path: "a/b/x"}, missing: true},
+					{song: song{path: "a/b/x"}, missing: true},
+					{song: song{path: "a/b/x"}, missing: true},
+				},
+			},
 			wantErr: true,
 		},
 		{
@@ -693,8 +901,8 @@ func TestPlaylistReadFrom(t *testing.T) {
 			t.Errorf("read byte counts not equal: wanted %v, got %v", wantN, n)
 		case err == nil:
 			t.Errorf("wanted error")
-		case len(p.tracks) != 0:
-			t.Errorf("wanted tracks to be set from broken read, even if an error occurs")
+		case len(p.tracks) != 3 || !p.tracks[0].missing:
+			t.Errorf("wanted missing tracks to be set from broken read, got %v", p.tracks)
 		}
 	})
 }
@@ -792,7 +1000,7 @@ func TestPlaylistWrite(t *testing.T) {
 			p: playlist{
 				tracks: []m3uTrack{
 					{display: "track 1", song: song{path: "a/b.mp3"}},
-					{display: "track 2", song: song{path: "r/b.mp3"}},
+					{display: "track 2", song: song{path: "r/b.mp3", duration: 184*time.Second + 600*time.Millisecond}},
 					{display: "Track 1, again :)", song: song{path: "a/b.mp3"}},
 				},
 				fsys: MockPlaylistFS{
@@ -814,7 +1022,7 @@ func TestPlaylistWrite(t *testing.T) {
 			want: "#EXTM3U\r\n" +
 				"#EXTINF:0, track 1\r\n" +
 				"a/b.mp3\r\n" +
-				"#EXTINF:0, track 2\r\n" +
+				"#EXTINF:185, track 2\r\n" +
 				"r/b.mp3\r\n" +
 				"#EXTINF:0, Track 1, again :)\r\n" +
 				"a/b.mp3\r\n",
@@ -842,6 +1050,261 @@ func TestPlaylistWrite(t *testing.T) {
 	}
 }
 
+// mockAbortWriteCloser is a MockWriteCloser that can also be aborted
+type mockAbortWriteCloser struct {
+	MockWriteCloser
+	AbortFunc func() error
+}
+
+func (w mockAbortWriteCloser) Abort() error {
+	return w.AbortFunc()
+}
+
+func TestPlaylistSave(t *testing.T) {
+	newFS := func(created, replaced *[]string, buf *bytes.Buffer) MockPlaylistFS {
+		f := MockWriteCloser{
+			Writer:    buf,
+			CloseFunc: func() error { return nil },
+		}
+		return MockPlaylistFS{
+			FS: fstest.MapFS{
+				"prev.m3u": &fstest.MapFile{Data: []byte("a.mp3")},
+			},
+			CreateFileFunc: func(name string) (io.WriteCloser, error) {
+				*created = append(*created, name)
+				return f, nil
+			},
+			ReplaceFileFunc: func(name string) (io.WriteCloser, error) {
+				*replaced = append(*replaced, name)
+				return f, nil
+			},
+		}
+	}
+	songs := []song{{path: "a.mp3", artist: "x", title: "y"}}
+	want := "#EXTM3U\r\n#EXTINF:0, x - y\r\na.mp3\r\n"
+	tests := []struct {
+		name         string
+		commands     func(p *playlist)
+		wantCreated  []string
+		wantReplaced []string
+		wantErr      bool
+	}{
+		{
+			name:     "no path",
+			commands: func(p *playlist) { p.save("") },
+			wantErr:  true,
+		},
+		{
+			name: "save loaded",
+			commands: func(p *playlist) {
+				p.load("prev.m3u")
+				p.save("")
+			},
+			wantReplaced: []string{"prev.m3u"},
+		},
+		{
+			name: "save written",
+			commands: func(p *playlist) {
+				p.write("new.m3u")
+				p.save("")
+			},
+			wantCreated:  []string{"new.m3u"},
+			wantReplaced: []string{"new.m3u"},
+		},
+		{
+			name: "save overwritten",
+			commands: func(p *playlist) {
+				p.load("prev.m3u")
+				p.overwrite("other.m3u")
+				p.save("")
+			},
+			wantReplaced: []string{"other.m3u", "other.m3u"},
+		},
+		{
+			name: "overwrite bad extension",
+			commands: func(p *playlist) {
+				p.overwrite("prev.mp3")
+			},
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var created, replaced []string
+			var buf, w bytes.Buffer
+			p := playlist{
+				songs:  songs,
+				tracks: []m3uTrack{{song: songs[0], display: "x - y"}},
+				fsys:   newFS(&created, &replaced, &buf),
+				w:      &w,
+			}
+			test.commands(&p)
+			gotErr := w.Len() != 0
+			switch {
+			case test.wantErr:
+				if !gotErr {
+					t.Error("wanted error")
+				}
+			case gotErr:
+				t.Errorf("unwanted error: %v", w.String())
+			case fmt.Sprint(test.wantCreated) != fmt.Sprint(created):
+				t.Errorf("created files not equal: wanted %q, got %q", test.wantCreated, created)
+			case fmt.Sprint(test.wantReplaced) != fmt.Sprint(replaced):
+				t.Errorf("replaced files not equal: wanted %q, got %q", test.wantReplaced, replaced)
+			case p.dirty:
+				t.Errorf("wanted playlist to not be dirty after it is saved")
+			case !bytes.HasSuffix(buf.Bytes(), []byte(want)):
+				t.Errorf("saved playlist not equal: \n wanted: %q \n got:    %q", want, buf.String())
+			}
+		})
+	}
+	t.Run("abort on write error", func(t *testing.T) {
+		var w bytes.Buffer
+		var aborted, closed bool
+		f := mockAbortWriteCloser{
+			MockWriteCloser: MockWriteCloser{
+				Writer:    &MockFixedBuffer{},
+				CloseFunc: func() error { closed = true; return nil },
+			},
+			AbortFunc: func() error { aborted = true; return nil },
+		}
+		p := playlist{
+			fsys: MockPlaylistFS{
+				ReplaceFileFunc: func(name string) (io.WriteCloser, error) {
+					return f, nil
+				},
+			},
+			w:     &w,
+			dirty: true,
+			path:  "prev.m3u",
+		}
+		p.save("")
+		switch {
+		case w.Len() == 0:
+			t.Error("wanted error")
+		case !aborted, closed:
+			t.Errorf("wanted file to be aborted and not closed, got aborted: %v, closed: %v", aborted, closed)
+		case !p.dirty:
+			t.Errorf("wanted playlist to still be dirty")
+		}
+	})
+}
+
+func TestPlaylistWriteRelativePaths(t *testing.T) {
+	songs := []song{
+		{path: "lists/a.mp3", title: "a"},
+		{path: "music/b.mp3", title: "b"},
+		{path: "../drive2/c.mp3", title: "c"},
+	}
+	tracks := []m3uTrack{
+		{song: songs[0], display: "a"},
+		{song: songs[1], display: "b"},
+		{song: songs[2], display: "c"},
+	}
+	for _, pf := range playlistFormats {
+		t.Run(pf.ext, func(t *testing.T) {
+			name := "lists/rock" + pf.ext
+			var buf, w bytes.Buffer
+			p := playlist{
+				songs:  songs,
+				tracks: copyTracks(tracks),
+				fsys: MockPlaylistFS{
+					FS: fstest.MapFS{},
+					CreateFileFunc: func(name string) (io.WriteCloser, error) {
+						return &MockWriteCloser{
+							Writer:    &buf,
+							CloseFunc: func() error { return nil },
+						}, nil
+					},
+				},
+				w: &w,
+			}
+			p.write(name)
+			for _, want := range []string{"a.mp3", "../music/b.mp3", "../../drive2/c.mp3"} {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("wanted written playlist to contain %q, got %q", want, buf.String())
+				}
+			}
+			p2 := playlist{
+				songs: songs,
+				fsys: MockPlaylistFS{
+					FS: fstest.MapFS{
+						name: &fstest.MapFile{Data: buf.Bytes()},
+					},
+				},
+				w: &w,
+			}
+			p2.load(name)
+			switch {
+			case w.Len() != 0:
+				t.Errorf("unwanted error: %v", w.String())
+			case fmt.Sprint(tracks) != fmt.Sprint(p2.tracks):
+				t.Errorf("loaded tracks not equal: \n wanted: %v \n got:    %v", tracks, p2.tracks)
+			}
+		})
+	}
+}
+
+func TestTrackResolverSongPath(t *testing.T) {
+	songs := []song{
+		{path: "lists/a.mp3"},
+		{path: "b.mp3"},
+	}
+	tests := []struct {
+		name         string
+		playlistPath string
+		entry        string
+		want         string
+	}{
+		{"library root", "rock.m3u", "b.mp3", "b.mp3"},
+		{"playlist folder", "lists/rock.m3u", "a.mp3", "lists/a.mp3"},
+		{"above playlist folder", "lists/rock.m3u", "../b.mp3", "b.mp3"},
+		{"relative to library root", "lists/rock.m3u", "b.mp3", "b.mp3"},
+		{"missing", "lists/rock.m3u", "c.mp3", "lists/c.mp3"},
+		{"absolute", "lists/rock.m3u", "/mnt/c.mp3", "/mnt/c.mp3"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := playlist{
+				songs: songs,
+				path:  test.playlistPath,
+			}
+			tr := p.newTrackResolver()
+			if got := tr.songPath(test.entry); test.want != got {
+				t.Errorf("song paths not equal: wanted %q, got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestPlaylistFormatOf(t *testing.T) {
+	tests := []struct {
+		name    string
+		wantExt string
+		wantOk  bool
+	}{
+		{"", "", false},
+		{".m3u", "", false},
+		{"list", "", false},
+		{"list.mp3", "", false},
+		{"list.m3u", ".m3u", true},
+		{"a/b/list.pls", ".pls", true},
+		{"LIST.PLS", ".pls", true},
+		{"list.pls.m3u", ".m3u", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pf, ok := playlistFormatOf(test.name)
+			switch {
+			case test.wantOk != ok:
+				t.Errorf("wanted format: %v, got %v", test.wantOk, ok)
+			case test.wantExt != pf.ext:
+				t.Errorf("extensions not equal: wanted %q, got %q", test.wantExt, pf.ext)
+			}
+		})
+	}
+}
+
 func TestSongLess(t *testing.T) {
 	songs := []song{
 		0: {},
@@ -851,6 +1314,9 @@ func TestSongLess(t *testing.T) {
 		4: {path: "pathA", artist: "artist0", album: "album3", title: "title2", track: 3},
 		5: {path: "pathA", artist: "artist0", album: "album3", title: "title1", track: 5},
 		6: {path: "pathA", artist: "artist0", album: "album3", title: "title0", track: 5},
+		7: {path: "pathA", artist: "artist0", album: "album3", title: "title0", track: 1, disc: 2},
+		8: {path: "pathC", artist: "artist2", albumArtist: "artist0", album: "album3", title: "title9", track: 9},
+		9: {path: "pathC", artist: "artist2", albumArtist: "artist0", album: "album3", title: "title0", track: 2, disc: 2},
 	}
 	tests := []struct {
 		name string
@@ -865,6 +1331,11 @@ func TestSongLess(t *testing.T) {
 		{"track third", 4, 5, true},
 		{"track third swapped", 5, 4, false},
 		{"title last", 5, 6, false},
+		{"disc before track", 6, 7, true},
+		{"disc before track swapped", 7, 6, false},
+		{"album artist instead of artist", 8, 2, true},
+		{"album artist and disc", 9, 7, false},
+		{"album artist and disc swapped", 7, 9, true},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
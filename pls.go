@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// readPLS reads the playlist tracks from a pls file, updating the playlist contain all valid songs in the file.
+// Entries are ordered by their numbers, not their position in the file.
+func (p *playlist) readPLS(r io.Reader) (n int64, err error) {
+	s := bufio.NewScanner(r)
+	files := make(map[int]string)
+	titles := make(map[int]string)
+	var errors []string
+	for s.Scan() {
+		line := s.Text()
+		n += int64(len(line))
+		line = strings.TrimSpace(line)
+		eqIndex := strings.Index(line, "=")
+		if eqIndex < 0 {
+			continue // [playlist] header, comment, or blank line
+		}
+		key, value := strings.ToLower(strings.TrimSpace(line[:eqIndex])), strings.TrimSpace(line[eqIndex+1:])
+		var entries map[int]string
+		switch {
+		case strings.HasPrefix(key, "file"):
+			entries, key = files, key[len("file"):]
+		case strings.HasPrefix(key, "title"):
+			entries, key = titles, key[len("title"):]
+		default:
+			continue // NumberOfEntries, Version, LengthN
+		}
+		i, err := strconv.Atoi(key)
+		if err != nil || i <= 0 {
+			errors = append(errors, fmt.Sprintf("invalid entry number: %q", line))
+			continue
+		}
+		entries[i] = value
+	}
+	indexes := make([]int, 0, len(files))
+	for i := range files {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	tr := p.newTrackResolver()
+	tr.errors = errors
+	for _, i := range indexes {
+		tr.add(tr.songPath(files[i]), titles[i])
+	}
+	p.tracks = tr.tracks
+	if err := s.Err(); err != nil {
+		return n, fmt.Errorf("reading playlist file: %v", err)
+	}
+	return n, tr.err()
+}
+
+// writePLS writes the tracks of the playlist as a version 2 pls file.
+// Tracks with unknown lengths have a length of -1.
+func (p playlist) writePLS(w io.Writer) (n int64, err error) {
+	n2, err := fmt.Fprint(w, "[playlist]\r\n")
+	n += int64(n2)
+	for i := 0; err == nil && i < len(p.tracks); i++ {
+		t := p.tracks[i]
+		length := durationSeconds(t.duration)
+		if t.duration <= 0 {
+			length = -1
+		}
+		id := i + 1
+		n2, err = fmt.Fprintf(w, "File%d=%v\r\nTitle%d=%v\r\nLength%d=%d\r\n", id, p.entryPath(t.path), id, t.display, id, length)
+		n += int64(n2)
+	}
+	if err == nil {
+		n2, err = fmt.Fprintf(w, "NumberOfEntries=%d\r\nVersion=2\r\n", len(p.tracks))
+		n += int64(n2)
+	}
+	return
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"testing/iotest"
+	"time"
+)
+
+func TestPlaylistReadPLS(t *testing.T) {
+	songs := []song{
+		{path: "d/g.mp3", artist: "x", title: "y", track: 1},
+		{path: "d/h.mp3", artist: "art", title: "word", track: 2},
+	}
+	tests := []struct {
+		name       string
+		data       string
+		wantTracks []m3uTrack
+		wantErr    bool
+	}{
+		{
+			name: "empty",
+			data: "[playlist]\r\nNumberOfEntries=0\r\nVersion=2\r\n",
+		},
+		{
+			name: "ok",
+			data: "[playlist]\r\n" +
+				"File1=d/h.mp3\r\n" +
+				"Title1=Track 1 title\r\n" +
+				"Length1=185\r\n" +
+				"File2=d/g.mp3\r\n" +
+				"Length2=-1\r\n" +
+				"NumberOfEntries=2\r\n" +
+				"Version=2\r\n",
+			wantTracks: []m3uTrack{
+				{song: songs[1], display: "Track 1 title"},
+				{song: songs[0], display: "x - y"},
+			},
+		},
+		{
+			name: "entries ordered by number, case-insensitive keys, spaces",
+			data: "[playlist]\n" +
+				"; comment\n" +
+				"\n" +
+				"file10 = d/g.mp3\n" +
+				"TITLE10=ten\n" +
+				"File9=d/h.mp3\n" +
+				"Title9=nine = 9\n",
+			wantTracks: []m3uTrack{
+				{song: songs[1], display: "nine = 9"},
+				{song: songs[0], display: "ten"},
+			},
+		},
+		{
+			name: "missing song",
+			data: "[playlist]\nFile1=UNKNOWN.mp3\nTitle1=?\nFile2=d/g.mp3\n",
+			wantTracks: []m3uTrack{
+				{song: song{path: "UNKNOWN.mp3"}, display: "?", missing: true},
+				{song: songs[0], display: "x - y"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "bad entry number",
+			data: "[playlist]\nFileOne=d/g.mp3\nFile2=d/h.mp3\n",
+			wantTracks: []m3uTrack{
+				{song: songs[1], display: "art - word"},
+			},
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := playlist{
+				songs:  songs,
+				tracks: []m3uTrack{{display: "old"}},
+			}
+			want := playlist{
+				songs:  songs,
+				tracks: test.wantTracks,
+			}
+			n, err := p.readPLS(strings.NewReader(test.data))
+			switch {
+			case test.wantErr != (err != nil):
+				t.Errorf("wanted error: %v, got %v", test.wantErr, err)
+			case n == 0:
+				t.Errorf("wanted bytes to be read")
+			}
+			checkPlaylistsEqual(t, want, p)
+		})
+	}
+	t.Run("read error", func(t *testing.T) {
+		r := iotest.ErrReader(fmt.Errorf("mock read playlist file error"))
+		p := playlist{
+			tracks: []m3uTrack{{}},
+		}
+		if _, err := p.readPLS(r); err == nil {
+			t.Errorf("wanted error")
+		}
+		if len(p.tracks) != 0 {
+			t.Errorf("wanted tracks to be set from broken read, even if an error occurs")
+		}
+	})
+}
+
+func TestPlaylistWritePLS(t *testing.T) {
+	p := playlist{
+		tracks: []m3uTrack{
+			{display: "track 1", song: song{path: "a/b.mp3", duration: 184*time.Second + 600*time.Millisecond}},
+			{display: "track 2", song: song{path: "r/b.mp3"}},
+		},
+	}
+	want := "[playlist]\r\n" +
+		"File1=a/b.mp3\r\n" +
+		"Title1=track 1\r\n" +
+		"Length1=185\r\n" +
+		"File2=r/b.mp3\r\n" +
+		"Title2=track 2\r\n" +
+		"Length2=-1\r\n" +
+		"NumberOfEntries=2\r\n" +
+		"Version=2\r\n"
+	var buf bytes.Buffer
+	n, err := p.writePLS(&buf)
+	switch {
+	case err != nil:
+		t.Errorf("unwanted error: %v", err)
+	case want != buf.String():
+		t.Errorf("pls files not equal: \n wanted: %q \n got:    %q", want, buf.String())
+	case int64(len(want)) != n:
+		t.Errorf("wanted %v bytes to be written, got %v", len(want), n)
+	}
+	t.Run("write error", func(t *testing.T) {
+		if _, err := p.writePLS(&MockFixedBuffer{Buf: make([]byte, 20)}); err == nil {
+			t.Errorf("wanted error")
+		}
+	})
+}
+
+func TestPlaylistPLSRoundTrip(t *testing.T) {
+	songs := []song{
+		{path: "a/b.mp3", artist: "x", title: "y"},
+		{path: "a/c.mp3", artist: "x", title: "z"},
+	}
+	src := "[playlist]\r\n" +
+		"File1=a/c.mp3\r\n" +
+		"Title1=custom name\r\n" +
+		"Length1=-1\r\n" +
+		"File2=a/b.mp3\r\n" +
+		"Title2=x - y\r\n" +
+		"Length2=-1\r\n" +
+		"NumberOfEntries=2\r\n" +
+		"Version=2\r\n"
+	var buf bytes.Buffer
+	var w bytes.Buffer
+	p := playlist{
+		songs: songs,
+		fsys: MockPlaylistFS{
+			FS: fstest.MapFS{
+				"in.PLS": &fstest.MapFile{Data: []byte(src)},
+			},
+			CreateFileFunc: func(name string) (io.WriteCloser, error) {
+				if want, got := "out.pls", name; want != got {
+					return nil, fmt.Errorf("names not equal: wanted %q, got %q", want, got)
+				}
+				f := MockWriteCloser{
+					Writer:    &buf,
+					CloseFunc: func() error { return nil },
+				}
+				return f, nil
+			},
+		},
+		w: &w,
+	}
+	p.load("in.PLS")
+	p.write("out.pls")
+	switch {
+	case w.Len() != 0:
+		t.Errorf("unwanted error: %v", w.String())
+	case src != buf.String():
+		t.Errorf("pls files not equal: \n wanted: %q \n got:    %q", src, buf.String())
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// progressModes are the ways the progress of reading songs can be displayed:
+// tty redraws a bar on one line of a terminal,
+// lines prints a line every ten percent, for logs and other files,
+// and quiet prints nothing.
+var progressModes = []string{"tty", "lines", "quiet"}
+
+// progressReporter displays the progress of reading songs
+type progressReporter interface {
+	// update displays the number of songs that have been read
+	update(done int)
+	// message prints a line, such as about a file that could not be read, without garbling the progress
+	message(text string)
+	// finish clears the progress so other messages can be printed
+	finish()
+}
+
+// newProgressReporter creates a reporter for the mode that displays the progress of reading the total number of songs.
+// The lines mode is used if the mode is not known.
+func newProgressReporter(mode string, w io.Writer, total int) progressReporter {
+	switch mode {
+	case "tty":
+		p := ttyProgress{
+			w:     w,
+			total: total,
+			start: time.Now(),
+			now:   time.Now,
+		}
+		return &p
+	case "quiet":
+		return quietProgress{}
+	}
+	p := lineProgress{
+		w:     w,
+		total: total,
+	}
+	return &p
+}
+
+// parseProgressMode checks the mode, replacing auto with tty if the file is a terminal, or lines otherwise
+func parseProgressMode(mode string, f *os.File) (string, error) {
+	mode = strings.ToLower(mode)
+	if mode == "auto" {
+		info, err := f.Stat()
+		if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+			return "lines", nil
+		}
+		return "tty", nil
+	}
+	for _, m := range progressModes {
+		if m == mode {
+			return mode, nil
+		}
+	}
+	return "", fmt.Errorf("unknown progress mode %q (wanted one of auto, %v)", mode, strings.Join(progressModes, ", "))
+}
+
+// ttyProgress redraws a progress bar with the rate and estimated time remaining on the current line of a terminal.
+// Carriage returns are used instead of escape sequences so it works on every terminal.
+// The bar is redrawn at most every ttyProgressInterval, except when all of the songs are read.
+type ttyProgress struct {
+	w        io.Writer
+	total    int
+	start    time.Time
+	now      func() time.Time
+	line     string    // the progress that was last drawn
+	drawnLen int       // the length of the text on the current line
+	drawn    time.Time // when the progress was last drawn
+}
+
+const (
+	// ttyProgressWidth is the number of characters in the progress bar
+	ttyProgressWidth = 20
+	// ttyProgressInterval is the minimum time between redraws of the progress bar, so terminals are not slowed down by reading many small files
+	ttyProgressInterval = 100 * time.Millisecond
+)
+
+func (p *ttyProgress) update(done int) {
+	now := p.now()
+	if done < p.total && !p.drawn.IsZero() && now.Sub(p.drawn) < ttyProgressInterval {
+		return
+	}
+	p.drawn = now
+	filled := 0
+	if p.total > 0 {
+		filled = done * ttyProgressWidth / p.total
+	}
+	bar := strings.Repeat("#", filled) + strings.Repeat("-", ttyProgressWidth-filled)
+	elapsed := now.Sub(p.start)
+	rate, eta := "?", "?"
+	if done > 0 && elapsed > 0 {
+		rate = fmt.Sprintf("%0.1f", float64(done)/elapsed.Seconds())
+		remaining := time.Duration(float64(elapsed) / float64(done) * float64(p.total-done))
+		eta = formatDuration(remaining)
+		if remaining < time.Second {
+			eta = "0:00"
+		}
+	}
+	p.line = fmt.Sprintf("> reading songs: [%v] %v/%v, %v songs/s, %v left", bar, done, p.total, rate, eta)
+	p.draw(p.line)
+}
+
+func (p *ttyProgress) message(text string) {
+	p.draw(text)
+	fmt.Fprint(p.w, "\n")
+	p.drawnLen = 0
+	if len(p.line) != 0 {
+		p.draw(p.line)
+	}
+}
+
+func (p *ttyProgress) finish() {
+	p.draw("")
+	fmt.Fprint(p.w, "\r")
+	p.line = ""
+}
+
+// draw replaces the text on the current line, padding it with spaces to cover longer text that was drawn before
+func (p *ttyProgress) draw(text string) {
+	padding := ""
+	if len(text) < p.drawnLen {
+		padding = strings.Repeat(" ", p.drawnLen-len(text))
+	}
+	fmt.Fprintf(p.w, "\r%v%v", text, padding)
+	p.drawnLen = len(text)
+}
+
+// lineProgress prints a line each time another tenth of the songs are read
+type lineProgress struct {
+	w      io.Writer
+	total  int
+	tenths int // the number of tenths of the songs that had been read when the last line was printed
+}
+
+func (p *lineProgress) update(done int) {
+	if p.total <= 0 {
+		return
+	}
+	tenths := done * 10 / p.total
+	if tenths == p.tenths {
+		return
+	}
+	p.tenths = tenths
+	fmt.Fprintf(p.w, "> reading songs: %v%% (%v/%v)\n", done*100/p.total, done, p.total)
+}
+
+func (p *lineProgress) message(text string) {
+	fmt.Fprintln(p.w, text)
+}
+
+func (p *lineProgress) finish() {}
+
+// quietProgress displays nothing
+type quietProgress struct{}
+
+func (quietProgress) update(done int)     {}
+func (quietProgress) message(text string) {}
+func (quietProgress) finish()             {}
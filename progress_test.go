@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewProgressReporter(t *testing.T) {
+	tests := []struct {
+		mode string
+		want string
+	}{
+		{"tty", "*main.ttyProgress"},
+		{"lines", "*main.lineProgress"},
+		{"", "*main.lineProgress"},
+		{"quiet", "main.quietProgress"},
+	}
+	for _, test := range tests {
+		var sb strings.Builder
+		p := newProgressReporter(test.mode, &sb, 10)
+		if got := fmt.Sprintf("%T", p); test.want != got {
+			t.Errorf("reporter for %q not equal: wanted %v, got %v", test.mode, test.want, got)
+		}
+	}
+}
+
+func TestParseProgressMode(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "log.txt"))
+	if err != nil {
+		t.Fatalf("creating file: %v", err)
+	}
+	defer f.Close()
+	tests := []struct {
+		mode    string
+		want    string
+		wantErr bool
+	}{
+		{"auto", "lines", false}, // not a terminal
+		{"TTY", "tty", false},
+		{"lines", "lines", false},
+		{"quiet", "quiet", false},
+		{"bar", "", true},
+	}
+	for _, test := range tests {
+		got, err := parseProgressMode(test.mode, f)
+		switch {
+		case test.wantErr:
+			if err == nil {
+				t.Errorf("wanted error parsing %q", test.mode)
+			}
+		case err != nil:
+			t.Errorf("unwanted error parsing %q: %v", test.mode, err)
+		case test.want != got:
+			t.Errorf("modes of %q not equal: wanted %q, got %q", test.mode, test.want, got)
+		}
+	}
+}
+
+func TestTTYProgress(t *testing.T) {
+	var sb strings.Builder
+	start := time.Date(2022, 7, 15, 0, 0, 0, 0, time.UTC)
+	now := start
+	p := ttyProgress{
+		w:     &sb,
+		total: 40,
+		start: start,
+		now:   func() time.Time { return now },
+	}
+	p.update(0)
+	now = start.Add(5 * time.Second)
+	p.update(10)
+	now = now.Add(ttyProgressInterval / 2)
+	p.update(11) // too soon to redraw
+	p.message("> a.mp3: no tags found")
+	p.update(40) // always drawn when done
+	p.finish()
+	bar0 := "> reading songs: [--------------------] 0/40, ? songs/s, ? left"
+	bar10 := "> reading songs: [#####---------------] 10/40, 2.0 songs/s, 0:15 left"
+	bar40 := "> reading songs: [####################] 40/40, 7.9 songs/s, 0:00 left"
+	want := "\r" + bar0 +
+		"\r" + bar10 + // longer, so no padding is needed
+
+		"\r> a.mp3: no tags found" + strings.Repeat(" ", len(bar10)-len("> a.mp3: no tags found")) + "\n" +
+		"\r" + bar10 +
+		"\r" + bar40 +
+		"\r" + strings.Repeat(" ", len(bar40)) + "\r"
+	if got := sb.String(); want != got {
+		t.Errorf("output not equal: \n wanted: %q \n got:    %q", want, got)
+	}
+	if strings.Contains(sb.String(), "\033") {
+		t.Errorf("wanted no escape sequences")
+	}
+}
+
+func TestLineProgress(t *testing.T) {
+	var sb strings.Builder
+	p := lineProgress{
+		w:     &sb,
+		total: 25,
+	}
+	for i := 1; i <= 25; i++ {
+		p.update(i)
+		if i == 4 {
+			p.message("> b.mp3: no tags found")
+		}
+	}
+	p.finish()
+	want := "> reading songs: 12% (3/25)\n" +
+		"> b.mp3: no tags found\n" +
+		"> reading songs: 20% (5/25)\n" +
+		"> reading songs: 32% (8/25)\n" +
+		"> reading songs: 40% (10/25)\n" +
+		"> reading songs: 52% (13/25)\n" +
+		"> reading songs: 60% (15/25)\n" +
+		"> reading songs: 72% (18/25)\n" +
+		"> reading songs: 80% (20/25)\n" +
+		"> reading songs: 92% (23/25)\n" +
+		"> reading songs: 100% (25/25)\n"
+	if got := sb.String(); want != got {
+		t.Errorf("output not equal: \n wanted: %q \n got:    %q", want, got)
+	}
+}
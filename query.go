@@ -0,0 +1,377 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// query is a parsed filter that songs are checked against.
+// Queries are made of terms, optionally qualified by a field (artist:beck).
+// Terms are combined with AND (the default), OR, NOT, and parentheses.
+type query interface {
+	matches(s song) bool
+}
+
+type (
+	queryAll struct{}
+	queryAnd struct {
+		left, right query
+	}
+	queryOr struct {
+		left, right query
+	}
+	queryNot struct {
+		q query
+	}
+	// queryText matches songs with the text in any of the standard fields
+	queryText struct {
+		text      string
+		checkHash bool
+	}
+	// queryField matches songs with the text in a specific field
+	queryField struct {
+		text  string
+		value func(s song) string
+	}
+	// queryNumber compares a numeric field of songs
+	queryNumber struct {
+		op    string
+		n     int
+		value func(s song) int
+	}
+)
+
+func (queryAll) matches(s song) bool   { return true }
+func (q queryAnd) matches(s song) bool { return q.left.matches(s) && q.right.matches(s) }
+func (q queryOr) matches(s song) bool  { return q.left.matches(s) || q.right.matches(s) }
+func (q queryNot) matches(s song) bool { return !q.q.matches(s) }
+
+func (q queryText) matches(s song) bool {
+	return s.matches(q.text, q.checkHash)
+}
+
+func (q queryField) matches(s song) bool {
+	return strings.Contains(strings.ToLower(q.value(s)), q.text)
+}
+
+func (q queryNumber) matches(s song) bool {
+	v := q.value(s)
+	switch q.op {
+	case "<":
+		return v < q.n
+	case "<=":
+		return v <= q.n
+	case ">":
+		return v > q.n
+	case ">=":
+		return v >= q.n
+	}
+	return v == q.n
+}
+
+// queryTextFields are the fields that can qualify query terms
+var queryTextFields = map[string]func(s song) string{
+	"artist":      func(s song) string { return s.artist },
+	"album":       func(s song) string { return s.album },
+	"title":       func(s song) string { return s.title },
+	"path":        func(s song) string { return s.path },
+	"hash":        func(s song) string { return s.hash },
+	"albumartist": func(s song) string { return s.albumArtist },
+	"composer":    func(s song) string { return s.composer },
+	"genre":       func(s song) string { return s.genre },
+	"comment":     func(s song) string { return s.comment },
+	"root":        func(s song) string { return s.root },
+}
+
+// queryNumberFields are the fields that can be compared to numbers in query terms
+var queryNumberFields = map[string]func(s song) int{
+	"track": func(s song) int { return s.track },
+	"disc":  func(s song) int { return s.disc },
+	"year":  func(s song) int { return s.year },
+}
+
+// queryNumberOps are the comparison operators for number fields, longest first
+var queryNumberOps = []string{"<=", ">=", "<", ">", "="}
+
+// queryError describes a problem with the query at a position
+type queryError struct {
+	pos, len int // 1-indexed
+	token    string
+	msg      string
+}
+
+func (e queryError) Error() string {
+	if len(e.token) == 0 {
+		return fmt.Sprintf("%v at position %d", e.msg, e.pos)
+	}
+	return fmt.Sprintf("%v at position %d: %q", e.msg, e.pos, e.token)
+}
+
+// marker creates a line that underlines the position of the error
+func (e queryError) marker() string {
+	n := e.len
+	if n < 1 {
+		n = 1
+	}
+	return strings.Repeat(" ", e.pos-1) + strings.Repeat("^", n)
+}
+
+type (
+	queryTokenKind int
+	queryToken     struct {
+		kind  queryTokenKind
+		text  string // the text of the token as it appears in the query
+		field string
+		value string
+		pos   int // 0-indexed
+	}
+)
+
+const (
+	queryEOF queryTokenKind = iota
+	queryTerm
+	queryLeftParen
+	queryRightParen
+	queryAndOp
+	queryOrOp
+	queryNotOp
+)
+
+// parseQuery creates a query from the text of a filter command
+func parseQuery(text string, checkHash bool) (query, error) {
+	tokens, err := tokenizeQuery(text)
+	if err != nil {
+		return nil, err
+	}
+	qp := queryParser{
+		tokens:    tokens,
+		checkHash: checkHash,
+	}
+	if qp.peek().kind == queryEOF {
+		return queryAll{}, nil
+	}
+	q, err := qp.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := qp.peek(); t.kind != queryEOF {
+		return nil, t.error("unexpected closing parenthesis")
+	}
+	return q, nil
+}
+
+func tokenizeQuery(text string) ([]queryToken, error) {
+	var tokens []queryToken
+	i := 0
+	for i < len(text) {
+		c := rune(text[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(':
+			tokens = append(tokens, queryToken{kind: queryLeftParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, queryToken{kind: queryRightParen, text: ")", pos: i})
+			i++
+		default:
+			t, err := readQueryTerm(text, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, t)
+			i += len(t.text)
+		}
+	}
+	tokens = append(tokens, queryToken{kind: queryEOF, pos: len(text)})
+	return tokens, nil
+}
+
+// readQueryTerm reads a word, quoted phrase, or operator starting at the index of the text
+func readQueryTerm(text string, start int) (queryToken, error) {
+	t := queryToken{
+		kind: queryTerm,
+		pos:  start,
+	}
+	end := start
+	for end < len(text) && !strings.ContainsRune(" \t()\"", rune(text[end])) {
+		end++
+	}
+	word := text[start:end]
+	if colon := strings.Index(word, ":"); colon > 0 {
+		t.field = strings.ToLower(word[:colon])
+		word = word[colon+1:]
+	}
+	switch {
+	case end < len(text) && text[end] == '"' && len(word) == 0:
+		closeQuote := strings.IndexByte(text[end+1:], '"')
+		if closeQuote < 0 {
+			return t, queryError{pos: end + 1, len: len(text) - end, token: text[end:], msg: "missing closing quote"}
+		}
+		word = text[end+1 : end+1+closeQuote]
+		end += closeQuote + 2
+	case len(t.field) == 0:
+		switch word {
+		case "AND":
+			t.kind = queryAndOp
+		case "OR":
+			t.kind = queryOrOp
+		case "NOT":
+			t.kind = queryNotOp
+		}
+	}
+	t.text = text[start:end]
+	t.value = word
+	return t, nil
+}
+
+type queryParser struct {
+	tokens    []queryToken
+	i         int
+	checkHash bool
+}
+
+func (qp *queryParser) peek() queryToken {
+	return qp.tokens[qp.i]
+}
+
+func (qp *queryParser) next() queryToken {
+	t := qp.tokens[qp.i]
+	if t.kind != queryEOF {
+		qp.i++
+	}
+	return t
+}
+
+// parseOr parses terms separated by OR, which has the lowest precedence
+func (qp *queryParser) parseOr() (query, error) {
+	left, err := qp.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for qp.peek().kind == queryOrOp {
+		qp.next()
+		right, err := qp.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = queryOr{left, right}
+	}
+	return left, nil
+}
+
+// parseAnd parses terms separated by AND or by nothing at all
+func (qp *queryParser) parseAnd() (query, error) {
+	left, err := qp.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch qp.peek().kind {
+		case queryEOF, queryRightParen, queryOrOp:
+			return left, nil
+		case queryAndOp:
+			qp.next()
+		}
+		right, err := qp.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = queryAnd{left, right}
+	}
+}
+
+func (qp *queryParser) parseNot() (query, error) {
+	if qp.peek().kind != queryNotOp {
+		return qp.parsePrimary()
+	}
+	qp.next()
+	q, err := qp.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	return queryNot{q}, nil
+}
+
+func (qp *queryParser) parsePrimary() (query, error) {
+	t := qp.next()
+	switch t.kind {
+	case queryEOF:
+		return nil, t.error("missing term")
+	case queryRightParen:
+		return nil, t.error("unexpected closing parenthesis")
+	case queryAndOp, queryOrOp:
+		return nil, t.error("missing term before operator")
+	case queryLeftParen:
+		q, err := qp.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if qp.next().kind != queryRightParen {
+			return nil, t.error("missing closing parenthesis for")
+		}
+		return q, nil
+	}
+	return t.term(qp.checkHash)
+}
+
+// term creates the query for a single term token
+func (t queryToken) term(checkHash bool) (query, error) {
+	if len(t.field) == 0 {
+		q := queryText{
+			text:      t.value,
+			checkHash: checkHash,
+		}
+		return q, nil
+	}
+	if len(t.value) == 0 {
+		return nil, t.error("missing value for field")
+	}
+	if value, ok := queryTextFields[t.field]; ok {
+		q := queryField{
+			text:  strings.ToLower(t.value),
+			value: value,
+		}
+		return q, nil
+	}
+	if value, ok := queryNumberFields[t.field]; ok {
+		op, number := "=", t.value
+		for _, o := range queryNumberOps {
+			if strings.HasPrefix(number, o) {
+				op, number = o, number[len(o):]
+				break
+			}
+		}
+		n, err := strconv.Atoi(number)
+		if err != nil {
+			return nil, t.error(fmt.Sprintf("%v must be compared to a number", t.field))
+		}
+		q := queryNumber{
+			op:    op,
+			n:     n,
+			value: value,
+		}
+		return q, nil
+	}
+	fields := make([]string, 0, len(queryTextFields)+len(queryNumberFields))
+	for f := range queryTextFields {
+		fields = append(fields, f)
+	}
+	for f := range queryNumberFields {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+	return nil, t.error(fmt.Sprintf("unknown field (wanted one of %v)", strings.Join(fields, ", ")))
+}
+
+func (t queryToken) error(msg string) error {
+	return queryError{
+		pos:   t.pos + 1,
+		len:   len(t.text),
+		token: t.text,
+		msg:   msg,
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import "testing"
+
+func TestParseQuery(t *testing.T) {
+	songs := []song{
+		0: {path: "who/tommy/01.mp3", artist: "The Who", album: "Tommy", title: "Overture", track: 1, hash: "f00d"},
+		1: {path: "who/tommy/02.mp3", artist: "The Who", album: "Tommy", title: "It's A Boy", track: 2},
+		2: {path: "who/next/05.mp3", artist: "The Who", album: "Who's Next", title: "Love Ain't for Keeping", track: 5},
+		3: {path: "beck/guero/04.mp3", artist: "Beck", album: "Guero", title: "Missing", track: 4, genre: "Alternative", year: 2005, composer: "Beck Hansen"},
+		4: {path: "love/forever/01.mp3", artist: "Love", album: "Forever Changes", title: "Alone Again Or", track: 1, albumArtist: "Various", disc: 2, year: 1967, comment: "remaster"},
+	}
+	tests := []struct {
+		name      string
+		text      string
+		checkHash bool
+		want      []int
+	}{
+		{"empty", "", false, []int{0, 1, 2, 3, 4}},
+		{"whitespace", "  \t ", false, []int{0, 1, 2, 3, 4}},
+		{"unqualified", "love", false, []int{2, 4}},
+		{"unqualified case-insensitive", "TOMMY", false, []int{0, 1}},
+		{"artist", "artist:love", false, []int{4}},
+		{"field case-insensitive", "ARTIST:Love", false, []int{4}},
+		{"title", "title:love", false, []int{2}},
+		{"album", "album:next", false, []int{2}},
+		{"path", "path:beck/", false, []int{3}},
+		{"hash not checked", "f00d", false, nil},
+		{"hash checked", "f00d", true, []int{0}},
+		{"hash field", "hash:f00d", false, []int{0}},
+		{"quoted phrase", `"it's a"`, false, []int{1}},
+		{"quoted field", `album:"who's next"`, false, []int{2}},
+		{"quoted keyword", `again "OR"`, false, []int{4}},
+		{"implicit and", "who love", false, []int{2}},
+		{"explicit and", "who AND love", false, []int{2}},
+		{"or", "beck OR artist:love", false, []int{3, 4}},
+		{"not", "NOT who", false, []int{3, 4}},
+		{"not not", "NOT NOT beck", false, []int{3}},
+		{"lowercase keywords are words", "again or", false, []int{4}},
+		{"and before or", "beck OR who love", false, []int{2, 3}},
+		{"parentheses", "(beck OR who) love", false, []int{2}},
+		{"nested parentheses", "((tommy) AND NOT (track:1))", false, []int{1}},
+		{"track equal", "track:1", false, []int{0, 4}},
+		{"track equal sign", "track:=4", false, []int{3}},
+		{"track less", "track:<2", false, []int{0, 4}},
+		{"track less equal", "track:<=2", false, []int{0, 1, 4}},
+		{"track greater", "track:>4", false, []int{2}},
+		{"track greater equal", "track:>=4", false, []int{2, 3}},
+		{"unqualified extended field", "alternative", false, []int{3}},
+		{"genre", "genre:alt", false, []int{3}},
+		{"album artist", "albumartist:various", false, []int{4}},
+		{"composer", "composer:hansen", false, []int{3}},
+		{"comment", "comment:remaster", false, []int{4}},
+		{"year", "year:<2000", false, []int{0, 1, 2, 4}}, // unknown years are 0
+		{"known year", "year:<2000 NOT year:0", false, []int{4}},
+		{"disc", "disc:2", false, []int{4}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			q, err := parseQuery(test.text, test.checkHash)
+			if err != nil {
+				t.Fatalf("unwanted error: %v", err)
+			}
+			var got []int
+			for i, s := range songs {
+				if q.matches(s) {
+					got = append(got, i)
+				}
+			}
+			if len(test.want) != len(got) {
+				t.Fatalf("matching songs not equal: wanted %v, got %v", test.want, got)
+			}
+			for i := range test.want {
+				if test.want[i] != got[i] {
+					t.Errorf("matching songs not equal: wanted %v, got %v", test.want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		text       string
+		wantPos    int
+		wantToken  string
+		wantMarker string
+	}{
+		{"unknown field", "beck artst:x", 6, "artst:x", "     ^^^^^^^"},
+		{"missing field value", "artist:", 1, "artist:", "^^^^^^^"},
+		{"track not a number", "who track:<two", 5, "track:<two", "    ^^^^^^^^^^"},
+		{"unterminated quote", `who "tommy`, 5, `"tommy`, "    ^^^^^^"},
+		{"unterminated field quote", `title:"tommy`, 7, `"tommy`, "      ^^^^^^"},
+		{"missing close paren", "(who OR beck", 1, "(", "^"},
+		{"extra close paren", "who)", 4, ")", "   ^"},
+		{"empty parens", "()", 2, ")", " ^"},
+		{"leading operator", "OR who", 1, "OR", "^^"},
+		{"trailing operator", "who AND", 8, "", "       ^"},
+		{"double operator", "who OR AND beck", 8, "AND", "       ^^^"},
+		{"trailing not", "who NOT", 8, "", "       ^"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := parseQuery(test.text, false)
+			qe, ok := err.(queryError)
+			switch {
+			case err == nil:
+				t.Error("wanted error")
+			case !ok:
+				t.Errorf("wanted queryError, got %T: %v", err, err)
+			case test.wantPos != qe.pos:
+				t.Errorf("error positions not equal: wanted %v, got %v (%v)", test.wantPos, qe.pos, err)
+			case test.wantToken != qe.token:
+				t.Errorf("error tokens not equal: wanted %q, got %q", test.wantToken, qe.token)
+			case test.wantMarker != qe.marker():
+				t.Errorf("error markers not equal: \n wanted: %q \n got:    %q", test.wantMarker, qe.marker())
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// relinkCandidateLimit is the maximum number of songs suggested for each missing track
+const relinkCandidateLimit = 3
+
+// relinkCandidate is a song that might be the moved file of a missing track
+type relinkCandidate struct {
+	song
+	score   int
+	reasons []string
+}
+
+// relinkCandidates finds the songs that might be the moved file of the missing track, best matches first.
+// Songs are matched by file name, display name, and the tags and hash of the song before it was moved, if known.
+func (p *playlist) relinkCandidates(m m3uTrack) []relinkCandidate {
+	prev, hasPrev := p.moved[m.path]
+	if !hasPrev && len(m.title) != 0 { // the tags of the song were read from the playlist file
+		prev, hasPrev = m.song, true
+	}
+	name := strings.ToLower(path.Base(m.path))
+	stem := strings.TrimSuffix(name, path.Ext(name))
+	display := strings.ToLower(m.display)
+	var candidates []relinkCandidate
+	for _, s := range p.songs {
+		c := relinkCandidate{
+			song: s,
+		}
+		add := func(score int, reason string) {
+			c.score += score
+			c.reasons = append(c.reasons, reason)
+		}
+		if p.showHash && hasPrev && len(prev.hash) != 0 && prev.hash == s.hash {
+			add(100, "same content hash")
+		}
+		songName := strings.ToLower(path.Base(s.path))
+		switch {
+		case songName == name:
+			add(40, "same file name")
+		case len(s.title) != 0 && strings.ToLower(s.title) == stem:
+			add(20, "file name matches title")
+		}
+		if hasPrev && len(prev.title) != 0 && strings.EqualFold(prev.artist, s.artist) && strings.EqualFold(prev.title, s.title) {
+			add(30, "same artist and title")
+		}
+		switch {
+		case len(display) == 0:
+			// NOOP
+		case display == strings.ToLower(s.display()):
+			add(30, "display name matches artist and title")
+		case len(s.title) != 0 && strings.Contains(display, strings.ToLower(s.title)):
+			add(10, "display name contains title")
+		}
+		if c.score > 0 {
+			candidates = append(candidates, c)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+	if len(candidates) > relinkCandidateLimit {
+		candidates = candidates[:relinkCandidateLimit]
+	}
+	return candidates
+}
+
+// relink asks which songs replace the tracks that are missing because they were not found when the playlist was loaded or the library was rescanned: relink [best].
+// The best candidate of each track is used without asking if best is given, such as in scripts.
+func (p *playlist) relink(command string) {
+	command = strings.TrimSpace(command)
+	if len(command) != 0 && command != "best" {
+		p.fail("Error (relink): wanted no argument or best, got %q\n", command)
+		return
+	}
+	best := command == "best"
+	var missingIndexes []int
+	for i, t := range p.tracks {
+		if t.missing {
+			missingIndexes = append(missingIndexes, i)
+		}
+	}
+	if len(missingIndexes) == 0 {
+		p.fail("Error (relink): no missing tracks, load a playlist or rescan the library first\n")
+		return
+	}
+	replaced := make(map[int]m3uTrack)
+	for _, i := range missingIndexes {
+		if t, ok := p.relinkTrack(p.tracks[i], best); ok {
+			replaced[i] = t
+		}
+	}
+	if len(replaced) != 0 {
+		p.record(fmt.Sprintf("relink %v tracks", len(replaced)))
+		for i, t := range replaced {
+			p.tracks[i] = t
+		}
+	}
+	fmt.Fprintf(p.w, "relinked %v tracks, %v are still missing\n", len(replaced), len(missingIndexes)-len(replaced))
+	if len(replaced) != 0 {
+		fmt.Fprintf(p.w, "save the playlist to update its paths\n")
+	}
+}
+
+// relinkTrack asks which candidate song replaces the missing track, using the best candidate without asking if best is true
+func (p *playlist) relinkTrack(m m3uTrack, best bool) (m3uTrack, bool) {
+	candidates := p.relinkCandidates(m)
+	if len(candidates) == 0 {
+		fmt.Fprintf(p.w, "no songs found for %q\n", m.path)
+		return m3uTrack{}, false
+	}
+	for _, c := range candidates {
+		if best {
+			fmt.Fprintf(p.w, "relinked %q to %q (%v)\n", m.path, c.path, strings.Join(c.reasons, ", "))
+		} else {
+			question := fmt.Sprintf("Relink %q to %q (%v)?", m.path, c.path, strings.Join(c.reasons, ", "))
+			if p.confirm == nil || !p.confirm(question) {
+				continue
+			}
+		}
+		display := m.display
+		if len(display) == 0 {
+			display = c.display()
+		}
+		t := m3uTrack{
+			song:    c.song,
+			display: display,
+		}
+		return t, true
+	}
+	return m3uTrack{}, false
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestPlaylistRelinkCandidates(t *testing.T) {
+	songs := []song{
+		{path: "new/a/b.mp3", artist: "x", title: "y", hash: "h1"},
+		{path: "new/c.mp3", artist: "x", title: "z", hash: "h2"},
+		{path: "new/Song Title.mp3", artist: "q", title: "Song Title", hash: "h3"},
+		{path: "new/d.mp3", artist: "r", title: "w", hash: "h4"},
+		{path: "new/e.mp3", artist: "s", title: "v", hash: "h5"},
+	}
+	moved := map[string]song{
+		"old/z.mp3":     {path: "old/z.mp3", artist: "X", title: "Z", hash: "h2"},
+		"old/other.mp3": {path: "old/other.mp3", hash: "h4"},
+	}
+	tests := []struct {
+		name      string
+		m         m3uTrack
+		showHash  bool
+		wantPaths []string
+	}{
+		{
+			name: "no matches",
+			m:    m3uTrack{song: song{path: "old/unknown.mp3"}},
+		},
+		{
+			name:      "same file name",
+			m:         m3uTrack{song: song{path: "old/b.mp3"}},
+			wantPaths: []string{"new/a/b.mp3"},
+		},
+		{
+			name:      "file name matches title",
+			m:         m3uTrack{song: song{path: "old/song title.m4a"}},
+			wantPaths: []string{"new/Song Title.mp3"},
+		},
+		{
+			name:      "display name",
+			m:         m3uTrack{song: song{path: "old/1.mp3"}, display: "X - Z"},
+			wantPaths: []string{"new/c.mp3"},
+		},
+		{
+			name:      "display name contains title, ordered by score",
+			m:         m3uTrack{song: song{path: "old/y.mp3"}, display: "my song y"},
+			wantPaths: []string{"new/a/b.mp3"},
+		},
+		{
+			name:      "previous tags",
+			m:         m3uTrack{song: song{path: "old/z.mp3"}},
+			wantPaths: []string{"new/c.mp3"},
+		},
+		{
+			name:      "hash ignored without showHash",
+			m:         m3uTrack{song: song{path: "old/other.mp3"}},
+			wantPaths: nil,
+		},
+		{
+			name:      "hash",
+			m:         m3uTrack{song: song{path: "old/other.mp3"}},
+			showHash:  true,
+			wantPaths: []string{"new/d.mp3"},
+		},
+		{
+			name:      "best first, limited",
+			m:         m3uTrack{song: song{path: "old/b.mp3"}, display: "s - v and w and z"},
+			wantPaths: []string{"new/a/b.mp3", "new/c.mp3", "new/d.mp3"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := playlist{
+				songs:    songs,
+				moved:    moved,
+				showHash: test.showHash,
+			}
+			candidates := p.relinkCandidates(test.m)
+			var gotPaths []string
+			for _, c := range candidates {
+				gotPaths = append(gotPaths, c.path)
+			}
+			if fmt.Sprint(test.wantPaths) != fmt.Sprint(gotPaths) {
+				t.Errorf("candidates not equal: \n wanted: %q \n got:    %q", test.wantPaths, gotPaths)
+			}
+		})
+	}
+}
+
+func TestPlaylistRelink(t *testing.T) {
+	songs := []song{
+		{path: "new/a.mp3", artist: "x", title: "a"},
+		{path: "new/b.mp3", artist: "x", title: "b"},
+		{path: "new/c.mp3", artist: "x", title: "c"},
+	}
+	src := "#EXTM3U\n" +
+		"#EXTINF:0, first\n" +
+		"old/a.mp3\n" +
+		"new/b.mp3\n" +
+		"old/c.mp3\n" +
+		"old/d.mp3\n"
+	var w bytes.Buffer
+	var questions []string
+	answers := []bool{true, false}
+	p := playlist{
+		songs: songs,
+		fsys: MockPlaylistFS{
+			FS: fstest.MapFS{
+				"list.m3u": &fstest.MapFile{Data: []byte(src)},
+			},
+		},
+		w: &w,
+		confirm: func(question string) bool {
+			questions = append(questions, question)
+			answer := answers[0]
+			answers = answers[1:]
+			return answer
+		},
+	}
+	p.load("list.m3u")
+	loadedTracks := []m3uTrack{
+		{song: song{path: "old/a.mp3"}, display: "first", missing: true},
+		{song: songs[1], display: "x - b"},
+		{song: song{path: "old/c.mp3"}, missing: true},
+		{song: song{path: "old/d.mp3"}, missing: true},
+	}
+	if want, got := 3, p.missingTracks(); want != got {
+		t.Fatalf("missing track counts not equal after load: wanted %v, got %v", want, got)
+	}
+	checkPlaylistsEqual(t, playlist{songs: songs, tracks: loadedTracks}, p)
+	if p.dirty {
+		t.Errorf("wanted playlist with missing tracks to not be dirty after it is loaded")
+	}
+	w.Reset()
+	p.relink("")
+	wantTracks := []m3uTrack{
+		{song: songs[0], display: "first"},
+		loadedTracks[1],
+		loadedTracks[2],
+		loadedTracks[3],
+	}
+	switch {
+	case len(questions) != 2:
+		t.Errorf("wanted a question for each missing track with candidates, got %q", questions)
+	case !strings.Contains(w.String(), `no songs found for "old/d.mp3"`):
+		t.Errorf("wanted message about track without candidates, got %q", w.String())
+	case !strings.Contains(w.String(), "relinked 1 tracks, 2 are still missing"):
+		t.Errorf("wanted relink summary, got %q", w.String())
+	case !p.dirty:
+		t.Errorf("wanted playlist to be dirty after relinking tracks")
+	default:
+		checkPlaylistsEqual(t, playlist{songs: songs, tracks: wantTracks}, p)
+	}
+	t.Run("undo", func(t *testing.T) {
+		p.undo("")
+		checkPlaylistsEqual(t, playlist{songs: songs, tracks: loadedTracks}, p)
+	})
+	t.Run("after tracks are moved", func(t *testing.T) {
+		var w bytes.Buffer
+		p := playlist{
+			songs: songs,
+			fsys: MockPlaylistFS{
+				FS: fstest.MapFS{
+					"list.m3u": &fstest.MapFile{Data: []byte(src)},
+				},
+			},
+			w: &w,
+		}
+		p.load("list.m3u")
+		p.removeTrack("2")
+		p.selection = songs
+		p.addTrack("1")
+		p.moveTrack("3 1")
+		p.failed = false
+		p.relink("best")
+		wantTracks := []m3uTrack{
+			{song: song{path: "old/d.mp3"}, missing: true},
+			{song: songs[0], display: "first"},
+			{song: songs[2], display: "x - c"},
+			{song: songs[0], display: "x - a"},
+		}
+		if fmt.Sprint(wantTracks) != fmt.Sprint(p.tracks) {
+			t.Errorf("tracks not equal: \n wanted: %v \n got:    %v", wantTracks, p.tracks)
+		}
+		if p.failed {
+			t.Errorf("unwanted failure: %q", w.String())
+		}
+	})
+	t.Run("invalid argument", func(t *testing.T) {
+		var w bytes.Buffer
+		p := playlist{
+			tracks: []m3uTrack{{song: song{path: "old/a.mp3"}, missing: true}},
+			w:      &w,
+		}
+		p.relink("all")
+		if !p.failed {
+			t.Errorf("wanted failure")
+		}
+	})
+	t.Run("rescanned missing tracks", func(t *testing.T) {
+		var w bytes.Buffer
+		p := playlist{
+			songs: songs,
+			tracks: []m3uTrack{
+				{song: song{path: "old/a.mp3", title: "a"}, display: "first", missing: true},
+				{song: songs[1], display: "second"},
+				{song: song{path: "old/z.mp3", title: "z"}, display: "third", missing: true},
+			},
+			moved: map[string]song{
+				"old/a.mp3": {path: "old/a.mp3", artist: "x", title: "a"},
+			},
+			w:       &w,
+			confirm: func(question string) bool { return true },
+		}
+		p.relink("")
+		wantTracks := []m3uTrack{
+			{song: songs[0], display: "first"},
+			{song: songs[1], display: "second"},
+			{song: song{path: "old/z.mp3", title: "z"}, display: "third", missing: true},
+		}
+		switch {
+		case !strings.Contains(w.String(), "relinked 1 tracks, 1 are still missing"):
+			t.Errorf("wanted relink summary, got %q", w.String())
+		case fmt.Sprint(wantTracks) != fmt.Sprint(p.tracks):
+			t.Errorf("tracks not equal: \n wanted: %v \n got:    %v", wantTracks, p.tracks)
+		case len(p.history.undo) != 1:
+			t.Errorf("wanted relink to be recorded")
+		}
+	})
+	t.Run("no missing tracks", func(t *testing.T) {
+		var w bytes.Buffer
+		p := playlist{
+			w: &w,
+		}
+		p.relink("")
+		if w.Len() == 0 {
+			t.Errorf("wanted error")
+		}
+	})
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// rescan reads the songs in the library again, reporting the files that were added, removed, or changed.
+// Tracks are updated to the songs that were read.
+// Tracks of songs that were removed are kept and marked as missing so they can be relinked.
+// The filter is cleared because the ids of the songs change.
+func (p *playlist) rescan(_ string) {
+	if p.readSongs == nil {
+		p.fail("Error (rescan): the library cannot be read again\n")
+		return
+	}
+	songs, loadErrors, err := p.readSongs(p.w)
+	if err != nil {
+		p.fail("Error (rescan): %v\n", err)
+		return
+	}
+	oldSongs := make(map[string]song, len(p.songs))
+	for _, s := range p.songs {
+		oldSongs[s.path] = s
+	}
+	newSongs := make(map[string]song, len(songs))
+	var added, changed []string
+	for _, s := range songs {
+		newSongs[s.path] = s
+		old, ok := oldSongs[s.path]
+		switch {
+		case !ok:
+			added = append(added, s.path)
+		case old != s:
+			changed = append(changed, s.path)
+		}
+	}
+	var removed []string
+	for path, s := range oldSongs {
+		if _, ok := newSongs[path]; !ok {
+			removed = append(removed, path)
+			if p.moved == nil {
+				p.moved = make(map[string]song)
+			}
+			p.moved[path] = s // help relink the tracks of the song
+		}
+	}
+	p.updateTracks(newSongs)
+	p.songs = make([]song, len(songs))
+	copy(p.songs, songs)
+	sort.Slice(p.songs, songLess(p.songs))
+	p.loadErrors = loadErrors
+	hadSelection := len(p.selection) != 0
+	p.selection = p.selection[:0]
+	p.scores = nil
+	p.printRescan(added, removed, changed)
+	if hadSelection {
+		fmt.Fprintf(p.w, "the filter was cleared, filter the songs again to add them\n")
+	}
+}
+
+// updateTracks sets the songs of the tracks to the songs at their paths.
+// Tracks without songs are marked as missing, which is recorded as a change.
+func (p *playlist) updateTracks(songs map[string]song) {
+	tracks := copyTracks(p.tracks)
+	missingChanged := 0
+	for i, t := range tracks {
+		s, ok := songs[t.path]
+		if ok {
+			tracks[i].song = s
+		}
+		if t.missing == ok {
+			tracks[i].missing = !ok
+			missingChanged++
+		}
+	}
+	if missingChanged != 0 {
+		p.record(fmt.Sprintf("update %v missing tracks after rescan", missingChanged))
+	}
+	p.tracks = tracks
+}
+
+// printRescan lists the paths of the songs that were added, removed, and changed when the library was read again
+func (p *playlist) printRescan(added, removed, changed []string) {
+	for _, paths := range []struct {
+		prefix string
+		paths  []string
+	}{
+		{"+", added},
+		{"-", removed},
+		{"~", changed},
+	} {
+		sort.Strings(paths.paths)
+		for _, path := range paths.paths {
+			fmt.Fprintf(p.w, "%v %v\n", paths.prefix, path)
+		}
+	}
+	fmt.Fprintf(p.w, "rescanned library: %v songs added, %v removed, %v changed\n", len(added), len(removed), len(changed))
+	if missing := p.missingTracks(); missing != 0 {
+		fmt.Fprintf(p.w, "%v tracks are missing, use relink to find their songs\n", missing)
+	}
+}
+
+// missingTracks counts the tracks with songs that are missing
+func (p *playlist) missingTracks() int {
+	missing := 0
+	for _, t := range p.tracks {
+		if t.missing {
+			missing++
+		}
+	}
+	return missing
+}
+
+// missingMarker is added to the display of tracks that are missing in listings
+const missingMarker = " (missing)"
+
+// listedDisplay is the display of the track in listings, marked if its song is missing.
+// Missing tracks without display names are listed by path.
+func (t m3uTrack) listedDisplay() string {
+	if t.missing {
+		if len(t.display) == 0 {
+			return t.path + missingMarker
+		}
+		return t.display + missingMarker
+	}
+	return t.display
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestPlaylistRescan(t *testing.T) {
+	oldSongs := []song{
+		{path: "a.mp3", artist: "x", title: "a"},
+		{path: "b.mp3", artist: "x", title: "b"},
+		{path: "c.mp3", artist: "x", title: "c"},
+	}
+	newSongs := []song{
+		{path: "d.mp3", artist: "x", title: "d"},
+		{path: "c.mp3", artist: "x", title: "c"},
+		{path: "a.mp3", artist: "x", title: "a2"},
+	}
+	newLoadErrors := []loadError{{path: "e.mp3", kind: "tags", err: "mock"}}
+	var w bytes.Buffer
+	p := playlist{
+		songs:     oldSongs,
+		selection: oldSongs[:2],
+		tracks: []m3uTrack{
+			{song: oldSongs[0], display: "first"},
+			{song: oldSongs[1], display: "second"},
+		},
+		w: &w,
+		readSongs: func(w io.Writer) ([]song, []loadError, error) {
+			return newSongs, newLoadErrors, nil
+		},
+	}
+	p.rescan("")
+	wantTracks := []m3uTrack{
+		{song: newSongs[2], display: "first"},
+		{song: oldSongs[1], display: "second", missing: true},
+	}
+	wantOutput := "+ d.mp3\n" +
+		"- b.mp3\n" +
+		"~ a.mp3\n" +
+		"rescanned library: 1 songs added, 1 removed, 1 changed\n" +
+		"1 tracks are missing, use relink to find their songs\n" +
+		"the filter was cleared, filter the songs again to add them\n"
+	switch {
+	case wantOutput != w.String():
+		t.Errorf("output not equal: \n wanted: %q \n got:    %q", wantOutput, w.String())
+	case fmt.Sprint(wantTracks) != fmt.Sprint(p.tracks):
+		t.Errorf("tracks not equal: \n wanted: %v \n got:    %v", wantTracks, p.tracks)
+	case fmt.Sprint([]song{newSongs[2], newSongs[1], newSongs[0]}) != fmt.Sprint(p.songs):
+		t.Errorf("wanted sorted songs to be replaced, got %v", p.songs)
+	case len(p.selection) != 0:
+		t.Errorf("wanted selection to be cleared, got %v", p.selection)
+	case p.moved["b.mp3"] != oldSongs[1]:
+		t.Errorf("wanted removed song to be moved, got %v", p.moved)
+	case fmt.Sprint(newLoadErrors) != fmt.Sprint(p.loadErrors):
+		t.Errorf("wanted load errors to be replaced, got %v", p.loadErrors)
+	case len(p.history.undo) != 1 || !p.dirty:
+		t.Errorf("wanted missing tracks to be recorded")
+	}
+	t.Run("print missing track", func(t *testing.T) {
+		w.Reset()
+		p.printTracks("")
+		if !strings.Contains(w.String(), "second (missing)") {
+			t.Errorf("wanted missing track to be marked, got %q", w.String())
+		}
+	})
+	t.Run("song restored", func(t *testing.T) {
+		w.Reset()
+		newSongs = oldSongs
+		p.selection = nil
+		p.rescan("")
+		wantOutput := "+ b.mp3\n" +
+			"- d.mp3\n" +
+			"~ a.mp3\n" +
+			"rescanned library: 1 songs added, 1 removed, 1 changed\n"
+		switch {
+		case wantOutput != w.String():
+			t.Errorf("output not equal: \n wanted: %q \n got:    %q", wantOutput, w.String())
+		case p.tracks[1].missing:
+			t.Errorf("wanted track to not be missing after its song is restored")
+		case len(p.history.undo) != 2:
+			t.Errorf("wanted track that is no longer missing to be recorded")
+		}
+	})
+}
+
+func TestPlaylistRescanErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		readSongs func(w io.Writer) ([]song, []loadError, error)
+		want      string
+	}{
+		{
+			name: "not supported",
+			want: "Error (rescan): the library cannot be read again\n",
+		},
+		{
+			name: "read error",
+			readSongs: func(w io.Writer) ([]song, []loadError, error) {
+				return nil, nil, fmt.Errorf("mock read error")
+			},
+			want: "Error (rescan): mock read error\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var w bytes.Buffer
+			songs := []song{{path: "a.mp3"}}
+			p := playlist{
+				songs:     songs,
+				selection: songs,
+				w:         &w,
+				readSongs: test.readSongs,
+			}
+			p.rescan("")
+			switch {
+			case test.want != w.String():
+				t.Errorf("output not equal: \n wanted: %q \n got:    %q", test.want, w.String())
+			case len(p.songs) != 1 || len(p.selection) != 1:
+				t.Errorf("wanted library to not change")
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// runScript evaluates the commands in the script to create playlists of the songs without prompting.
+// Questions, such as confirming to discard unsaved changes, are answered with no, failing the command that asked.
+func (fsys *osFS) runScript(songs []song, movedSongs map[string]song, loadErrors []loadError, script io.Reader, w io.Writer, showHash, keepGoing bool) error {
+	p, cmds := fsys.newPlaylistCommands(songs, movedSongs, loadErrors, w, showHash)
+	p.confirm = func(question string) bool {
+		fmt.Fprintf(w, "%v [y/N] n\n", question)
+		p.fail("Error (script): questions cannot be answered in scripts\n")
+		return false
+	}
+	return cmds.runScript(bufio.NewScanner(script), w, &p.failed, keepGoing)
+}
+
+// runScript evaluates commands from the scanner without prompts until the script ends or it quits.
+// Blank lines and comments, which start with #, are skipped.
+// Commands fail if they are invalid or set the failed flag, which is cleared before each command.
+// It stops at the first command that fails unless keepGoing is true.
+func (cmds commands) runScript(s *bufio.Scanner, w io.Writer, failed *bool, keepGoing bool) error {
+	lookup, err := cmds.lookup(w)
+	if err != nil {
+		return fmt.Errorf("preparing to run commands: %v", err)
+	}
+	var failures []string
+	for lineNumber := 1; s.Scan(); lineNumber++ {
+		line := strings.TrimSpace(s.Text())
+		switch {
+		case len(line) == 0, line[0] == '#':
+			continue
+		case line == "q":
+			return scriptFailures(failures)
+		}
+		*failed = false
+		if !runLine(lookup, line) {
+			fmt.Fprintf(w, "Error (invalid command): %v\n", line)
+			*failed = true
+		}
+		if !*failed {
+			continue
+		}
+		failures = append(failures, fmt.Sprintf("line %v: %v", lineNumber, line))
+		if !keepGoing {
+			return scriptFailures(failures)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return fmt.Errorf("reading script: %v", err)
+	}
+	return scriptFailures(failures)
+}
+
+// scriptFailures combines the commands that failed into an error, if any failed
+func scriptFailures(failures []string) error {
+	if len(failures) == 0 {
+		return nil
+	}
+	return fmt.Errorf("failed commands:\n%v", strings.Join(failures, "\n"))
+}
+
+// commandsFlag collects script commands from repeated flags.
+// Each flag has one or more commands separated by semicolons, so commands cannot have arguments with semicolons.
+type commandsFlag []string
+
+// String lists the commands
+func (f *commandsFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return strings.Join(*f, "; ")
+}
+
+// Set adds the commands separated by semicolons or line breaks, skipping empty commands
+func (f *commandsFlag) Set(value string) error {
+	commands := strings.FieldsFunc(value, func(r rune) bool {
+		return r == ';' || r == '\r' || r == '\n'
+	})
+	added := false
+	for _, c := range commands {
+		if c = strings.TrimSpace(c); len(c) != 0 {
+			*f = append(*f, c)
+			added = true
+		}
+	}
+	if !added {
+		return fmt.Errorf("no script commands in %q", value)
+	}
+	return nil
+}
+
+// script is a script of the commands, one per line
+func (f commandsFlag) script() io.Reader {
+	return strings.NewReader(strings.Join(f, "\n"))
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestCommandsFlag(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  []string
+		want    string
+		wantErr bool
+	}{
+		{"none", nil, "", false},
+		{"one", []string{"a *"}, "a *", false},
+		{"separated by semicolons", []string{"f beck; a *; w x.m3u"}, "f beck\na *\nw x.m3u", false},
+		{"repeated", []string{"f artist:beck;a *", " w! beck.m3u "}, "f artist:beck\na *\nw! beck.m3u", false},
+		{"empty commands skipped", []string{";f beck;; a *;"}, "f beck\na *", false},
+		{"line breaks", []string{"a 1\na 2"}, "a 1\na 2", false},
+		{"only semicolons", []string{" ; ;"}, "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var f commandsFlag
+			var err error
+			for _, v := range test.values {
+				if err = f.Set(v); err != nil {
+					break
+				}
+			}
+			switch {
+			case test.wantErr:
+				if err == nil {
+					t.Errorf("wanted error")
+				}
+			case err != nil:
+				t.Errorf("unwanted error: %v", err)
+			default:
+				b, _ := io.ReadAll(f.script())
+				if test.want != string(b) {
+					t.Errorf("scripts not equal: wanted %q, got %q", test.want, string(b))
+				}
+			}
+		})
+	}
+}
+
+func TestRunScript(t *testing.T) {
+	tests := []struct {
+		name      string
+		script    string
+		keepGoing bool
+		wantRan   string
+		wantErr   bool
+	}{
+		{
+			name:    "empty",
+			script:  "",
+			wantRan: "",
+		},
+		{
+			name:    "comments and blank lines",
+			script:  "# comment\n\n  a 1\n\t\n  # another comment\na 2\n",
+			wantRan: "[a1 a2]",
+		},
+		{
+			name:    "quit",
+			script:  "a 1\nq\na 2",
+			wantRan: "[a1]",
+		},
+		{
+			name:    "stop on error",
+			script:  "a 1\nz\na 2",
+			wantRan: "[a1 z]",
+			wantErr: true,
+		},
+		{
+			name:    "stop on invalid command",
+			script:  "a 1\nj\na 2",
+			wantRan: "[a1]",
+			wantErr: true,
+		},
+		{
+			name:      "keep going",
+			script:    "a 1\nz\nj\na 2",
+			keepGoing: true,
+			wantRan:   "[a1 z a2]",
+			wantErr:   true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var ran []string
+			var sb strings.Builder
+			var failed bool
+			cmds := commands{
+				{"a", func(command string) { ran = append(ran, "a"+command) }, ""},
+				{"z", func(command string) { ran = append(ran, "z"); failed = true }, ""},
+			}
+			s := bufio.NewScanner(strings.NewReader(test.script))
+			err := cmds.runScript(s, &sb, &failed, test.keepGoing)
+			switch {
+			case test.wantErr != (err != nil):
+				t.Errorf("wanted error: %v, got %v", test.wantErr, err)
+			case strings.Contains(sb.String(), "> "):
+				t.Errorf("unwanted prompt in output: %q", sb.String())
+			}
+			if len(test.wantRan) != 0 && test.wantRan != fmt.Sprint(ran) {
+				t.Errorf("commands run not equal: wanted %v, got %v", test.wantRan, ran)
+			}
+		})
+	}
+}
+
+func TestOsFSRunScript(t *testing.T) {
+	songs := []song{
+		{path: "e.mp3", artist: "b", album: "c", title: "e", track: 2},
+		{path: "d.mp3", artist: "b", album: "c", title: "d", track: 1},
+	}
+	commands := func(values ...string) string {
+		var f commandsFlag
+		for _, v := range values {
+			if err := f.Set(v); err != nil {
+				t.Fatalf("setting commands %q: %v", v, err)
+			}
+		}
+		b, _ := io.ReadAll(f.script())
+		return string(b)
+	}
+	tests := []struct {
+		name    string
+		script  string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "commands separated by semicolons",
+			script: commands("f b; a 2", "w! out.m3u"),
+			want:   "#EXTM3U\r\n#EXTINF:0, b - e\r\ne.mp3\r\n",
+		},
+		{
+			name: "saved before clearing",
+			script: "# make a playlist\n" +
+				"f b\n" +
+				"a 2\n" +
+				"a 1\n" +
+				"w! first.m3u\n" +
+				"c\n" + // not asked because the playlist is saved
+				"a 2\n" +
+				"w! out.m3u\n",
+			want: "#EXTM3U\r\n#EXTINF:0, b - e\r\ne.mp3\r\n",
+		},
+		{
+			name: "questions answered with no",
+			script: "f b\n" +
+				"a 2\n" +
+				"a 1\n" +
+				"c\n" +
+				"a 2\n" +
+				"w! out.m3u\n",
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var sb strings.Builder
+			fsys := osFS{
+				FS: fstest.MapFS{},
+				replaceFileFunc: func(name string) (io.WriteCloser, error) {
+					if name != "out.m3u" {
+						return MockWriteCloser{Writer: io.Discard, CloseFunc: func() error { return nil }}, nil
+					}
+					return MockWriteCloser{Writer: &sb, CloseFunc: func() error { return nil }}, nil
+				},
+			}
+			var output strings.Builder
+			err := fsys.runScript(songs, nil, nil, strings.NewReader(test.script), &output, false, false)
+			switch {
+			case test.wantErr:
+				if err == nil {
+					t.Errorf("wanted error")
+				}
+				if !strings.Contains(output.String(), "[y/N] n") {
+					t.Errorf("wanted question answered with no in output: %q", output.String())
+				}
+			case err != nil:
+				t.Fatalf("unwanted error: %v\n%v", err, output.String())
+			}
+			if got := sb.String(); test.want != got {
+				t.Errorf("written playlists not equal: \n wanted: %q \n got:    %q", test.want, got)
+			}
+			if strings.Contains(output.String(), "Help for") {
+				t.Errorf("unwanted help in output: %q", output.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// shuffleModes are the constraints the shuffle command can keep, by name
+var shuffleModes = map[string]func(tracks []m3uTrack, r *rand.Rand) []m3uTrack{
+	"artist": shuffleArtistSeparated,
+	"album":  shuffleAlbumsContiguous,
+	"spread": shuffleArtistSpread,
+}
+
+// shuffle randomly reorders the playlist tracks: shuffle [artist|album|spread] [seed].
+// The artist mode keeps tracks by the same artist apart, the album mode keeps the tracks of albums together in order,
+// and the spread mode spreads the tracks of each artist evenly through the playlist.
+// The seed can be reused to shuffle the same tracks in the same order.
+func (p *playlist) shuffle(command string) {
+	mode, seed, err := parseShuffleCommand(command)
+	if err != nil {
+		p.fail("Error (shuffle): %v\n", err)
+		return
+	}
+	if len(p.tracks) < 2 {
+		p.fail("Error (shuffle): need at least two tracks to shuffle\n")
+		return
+	}
+	r := rand.New(rand.NewSource(seed))
+	tracks := copyTracks(p.tracks)
+	switch f, ok := shuffleModes[mode]; {
+	case ok:
+		tracks = f(tracks, r)
+	default:
+		r.Shuffle(len(tracks), func(i, j int) {
+			tracks[i], tracks[j] = tracks[j], tracks[i]
+		})
+	}
+	description := "shuffle tracks"
+	if len(mode) != 0 {
+		description += " by " + mode
+	}
+	p.record(fmt.Sprintf("%v with seed %v", description, seed))
+	p.tracks = tracks
+	fmt.Fprintf(p.w, "shuffled %v tracks with seed %v\n", len(tracks), seed)
+	if mode == "artist" || mode == "spread" {
+		if n := adjacentArtistCount(tracks); n != 0 {
+			fmt.Fprintf(p.w, "could not keep all tracks by the same artist apart: %v tracks follow a track by the same artist\n", n)
+		}
+	}
+}
+
+// parseShuffleCommand reads the optional mode and seed of the shuffle command.
+// The seed is random if it is not in the command.
+func parseShuffleCommand(command string) (mode string, seed int64, err error) {
+	hasSeed := false
+	for _, f := range strings.Fields(command) {
+		if s, err := strconv.ParseInt(f, 10, 64); err == nil {
+			if hasSeed {
+				return "", 0, fmt.Errorf("multiple seeds: %q", command)
+			}
+			seed, hasSeed = s, true
+			continue
+		}
+		if _, ok := shuffleModes[f]; !ok {
+			return "", 0, fmt.Errorf("unknown mode %q, wanted one of %v", f, shuffleModeNames())
+		}
+		if len(mode) != 0 {
+			return "", 0, fmt.Errorf("multiple modes: %q", command)
+		}
+		mode = f
+	}
+	if !hasSeed {
+		seed = time.Now().UnixNano()
+	}
+	return mode, seed, nil
+}
+
+// shuffleModeNames lists the sorted names of the shuffle modes
+func shuffleModeNames() string {
+	names := make([]string, 0, len(shuffleModes))
+	for name := range shuffleModes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// shuffleGroups groups the tracks by the key, keeping the order of the tracks in each group.
+// Tracks with empty keys are each in their own group.
+func shuffleGroups(tracks []m3uTrack, key func(t m3uTrack) string) [][]m3uTrack {
+	var groups [][]m3uTrack
+	groupIndexes := make(map[string]int)
+	for _, t := range tracks {
+		k := strings.ToLower(key(t))
+		i, ok := groupIndexes[k]
+		if !ok || len(k) == 0 {
+			i = len(groups)
+			groups = append(groups, nil)
+			groupIndexes[k] = i
+		}
+		groups[i] = append(groups[i], t)
+	}
+	return groups
+}
+
+// trackArtist is the artist of the track
+func trackArtist(t m3uTrack) string {
+	return t.artist
+}
+
+// shuffleArtistSeparated shuffles the tracks so no two tracks in a row are by the same artist, if possible.
+// Each track is randomly picked from the artists that can be next without making the constraint impossible to keep.
+func shuffleArtistSeparated(tracks []m3uTrack, r *rand.Rand) []m3uTrack {
+	groups := shuffleGroups(tracks, trackArtist)
+	for _, g := range groups {
+		r.Shuffle(len(g), func(i, j int) {
+			g[i], g[j] = g[j], g[i]
+		})
+	}
+	shuffled := make([]m3uTrack, 0, len(tracks))
+	prev := -1
+	for remaining := len(tracks); remaining > 0; remaining-- {
+		var candidates []int
+		weight := 0
+		for i, g := range groups {
+			if i != prev && len(g) != 0 && artistsSeparable(groups, i, remaining-1) {
+				candidates = append(candidates, i)
+				weight += len(g)
+			}
+		}
+		next := -1
+		switch {
+		case len(candidates) != 0:
+			n := r.Intn(weight)
+			for _, i := range candidates {
+				if n < len(groups[i]) {
+					next = i
+					break
+				}
+				n -= len(groups[i])
+			}
+		default: // the constraint cannot be kept, pick the artist with the most tracks, preferring a different artist
+			for i, g := range groups {
+				if len(g) == 0 {
+					continue
+				}
+				if next < 0 || next == prev && i != prev || i != prev && len(groups[next]) < len(g) {
+					next = i
+				}
+			}
+		}
+		shuffled = append(shuffled, groups[next][0])
+		groups[next] = groups[next][1:]
+		prev = next
+	}
+	return shuffled
+}
+
+// artistsSeparable determines if the remaining tracks can be ordered without two tracks in a row by the same artist
+// after a track by the artist of the picked group is added to the playlist.
+// No artist can have more than about half of the remaining tracks.
+func artistsSeparable(groups [][]m3uTrack, picked, remaining int) bool {
+	for i, g := range groups {
+		n := len(g)
+		if i == picked {
+			if 2*(n-1) > remaining { // the next track cannot also be by the picked artist
+				return false
+			}
+			continue
+		}
+		if 2*n > remaining+1 {
+			return false
+		}
+	}
+	return true
+}
+
+// trackAlbum identifies the album of the track by its album artist, or artist, and its name.
+// Tracks without an album are not grouped.
+func trackAlbum(t m3uTrack) string {
+	if len(t.album) == 0 {
+		return ""
+	}
+	return t.sortArtist() + "\x00" + t.album
+}
+
+// shuffleAlbumsContiguous shuffles the order of albums, keeping the tracks of each album together and in order
+func shuffleAlbumsContiguous(tracks []m3uTrack, r *rand.Rand) []m3uTrack {
+	groups := shuffleGroups(tracks, trackAlbum)
+	r.Shuffle(len(groups), func(i, j int) {
+		groups[i], groups[j] = groups[j], groups[i]
+	})
+	shuffled := make([]m3uTrack, 0, len(tracks))
+	for _, g := range groups {
+		shuffled = append(shuffled, g...)
+	}
+	return shuffled
+}
+
+// shuffleArtistSpread spreads the tracks of each artist evenly through the playlist.
+// The tracks of an artist with n tracks are placed about every 1/n of the playlist, starting at a random offset.
+func shuffleArtistSpread(tracks []m3uTrack, r *rand.Rand) []m3uTrack {
+	type spreadTrack struct {
+		m3uTrack
+		position float64
+	}
+	spread := make([]spreadTrack, 0, len(tracks))
+	for _, g := range shuffleGroups(tracks, trackArtist) {
+		r.Shuffle(len(g), func(i, j int) {
+			g[i], g[j] = g[j], g[i]
+		})
+		n := float64(len(g))
+		offset := r.Float64() / n
+		for i, t := range g {
+			jitter := (r.Float64() - 0.5) * 0.1 / n
+			st := spreadTrack{
+				m3uTrack: t,
+				position: offset + float64(i)/n + jitter,
+			}
+			spread = append(spread, st)
+		}
+	}
+	sort.SliceStable(spread, func(i, j int) bool {
+		return spread[i].position < spread[j].position
+	})
+	shuffled := make([]m3uTrack, len(spread))
+	for i, st := range spread {
+		shuffled[i] = st.m3uTrack
+	}
+	return shuffled
+}
+
+// adjacentArtistCount counts the tracks that follow a track by the same artist
+func adjacentArtistCount(tracks []m3uTrack) int {
+	n := 0
+	for i := 1; i < len(tracks); i++ {
+		a, b := tracks[i-1].artist, tracks[i].artist
+		if len(a) != 0 && strings.EqualFold(a, b) {
+			n++
+		}
+	}
+	return n
+}
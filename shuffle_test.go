@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// mockArtistTracks creates tracks by the artists, numbering the titles of each artist's tracks
+func mockArtistTracks(artists ...string) []m3uTrack {
+	tracks := make([]m3uTrack, len(artists))
+	counts := make(map[string]int)
+	for i, a := range artists {
+		counts[a]++
+		tracks[i] = m3uTrack{
+			song:    song{artist: a, album: a + " album", title: fmt.Sprintf("%v%d", a, counts[a])},
+			display: fmt.Sprintf("%v%d", a, counts[a]),
+		}
+	}
+	return tracks
+}
+
+// trackDisplays lists the display names of the tracks
+func trackDisplays(tracks []m3uTrack) []string {
+	displays := make([]string, len(tracks))
+	for i, t := range tracks {
+		displays[i] = t.display
+	}
+	return displays
+}
+
+// checkSameTracks checks the shuffled tracks are a reordering of the tracks
+func checkSameTracks(t *testing.T, tracks, shuffled []m3uTrack) {
+	t.Helper()
+	a, b := trackDisplays(tracks), trackDisplays(shuffled)
+	sort.Strings(a)
+	sort.Strings(b)
+	if fmt.Sprint(a) != fmt.Sprint(b) {
+		t.Errorf("shuffled tracks are not the same as the tracks: \n wanted: %v \n got:    %v", a, b)
+	}
+}
+
+func TestParseShuffleCommand(t *testing.T) {
+	tests := []struct {
+		command  string
+		wantMode string
+		wantSeed int64
+		wantErr  bool
+	}{
+		{"", "", 0, false},
+		{"artist", "artist", 0, false},
+		{"album 42", "album", 42, false},
+		{"-7 spread", "spread", -7, false},
+		{"7", "", 7, false},
+		{"tracks", "", 0, true},
+		{"artist album", "", 0, true},
+		{"1 2", "", 0, true},
+	}
+	for _, test := range tests {
+		t.Run(test.command, func(t *testing.T) {
+			mode, seed, err := parseShuffleCommand(test.command)
+			switch {
+			case test.wantErr:
+				if err == nil {
+					t.Error("wanted error")
+				}
+			case err != nil:
+				t.Errorf("unwanted error: %v", err)
+			case test.wantMode != mode:
+				t.Errorf("modes not equal: wanted %q, got %q", test.wantMode, mode)
+			case test.wantSeed != 0 && test.wantSeed != seed:
+				t.Errorf("seeds not equal: wanted %v, got %v", test.wantSeed, seed)
+			}
+		})
+	}
+}
+
+func TestShuffleArtistSeparated(t *testing.T) {
+	tests := []struct {
+		name         string
+		tracks       []m3uTrack
+		wantAdjacent int
+	}{
+		{"one", mockArtistTracks("a"), 0},
+		{"alternating", mockArtistTracks("a", "a", "b", "b"), 0},
+		{"half", mockArtistTracks("a", "a", "a", "b", "c"), 0},
+		{"many", mockArtistTracks("a", "a", "a", "a", "b", "b", "b", "c", "c", "d", "e"), 0},
+		{"unknown artists", mockArtistTracks("", "", "", "a"), 0},
+		{"too many by one artist", mockArtistTracks("a", "a", "a", "a", "b"), 2},
+		{"only one artist", mockArtistTracks("a", "a", "a"), 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for seed := int64(0); seed < 50; seed++ {
+				r := rand.New(rand.NewSource(seed))
+				got := shuffleArtistSeparated(copyTracks(test.tracks), r)
+				checkSameTracks(t, test.tracks, got)
+				if n := adjacentArtistCount(got); test.wantAdjacent != n {
+					t.Fatalf("seed %v: tracks following the same artist not equal: wanted %v, got %v: %v", seed, test.wantAdjacent, n, trackDisplays(got))
+				}
+			}
+		})
+	}
+}
+
+func TestShuffleAlbumsContiguous(t *testing.T) {
+	tracks := mockArtistTracks("a", "b", "a", "c", "b", "a")
+	tracks = append(tracks, m3uTrack{display: "no album 1"}, m3uTrack{display: "no album 2"})
+	for seed := int64(0); seed < 20; seed++ {
+		r := rand.New(rand.NewSource(seed))
+		got := shuffleAlbumsContiguous(copyTracks(tracks), r)
+		checkSameTracks(t, tracks, got)
+		joined := strings.Join(trackDisplays(got), " ")
+		for _, album := range []string{"a1 a2 a3", "b1 b2", "c1"} {
+			if !strings.Contains(joined, album) {
+				t.Errorf("seed %v: wanted album tracks %q together in order, got %q", seed, album, joined)
+			}
+		}
+	}
+}
+
+func TestShuffleAlbumsContiguousSameName(t *testing.T) {
+	tracks := []m3uTrack{
+		{song: song{artist: "x", album: "greatest hits", title: "x1"}, display: "x1"},
+		{song: song{artist: "y", album: "greatest hits", title: "y1"}, display: "y1"},
+		{song: song{artist: "x", album: "greatest hits", title: "x2"}, display: "x2"},
+		{song: song{artist: "y", album: "greatest hits", title: "y2"}, display: "y2"},
+		{song: song{artist: "z1", albumArtist: "various", album: "mix", title: "z1"}, display: "z1"},
+		{song: song{artist: "y", album: "mix", title: "y3"}, display: "y3"},
+		{song: song{artist: "z2", albumArtist: "various", album: "mix", title: "z2"}, display: "z2"},
+	}
+	for seed := int64(0); seed < 20; seed++ {
+		r := rand.New(rand.NewSource(seed))
+		got := shuffleAlbumsContiguous(copyTracks(tracks), r)
+		checkSameTracks(t, tracks, got)
+		joined := strings.Join(trackDisplays(got), " ")
+		for _, album := range []string{"x1 x2", "y1 y2", "z1 z2"} {
+			if !strings.Contains(joined, album) {
+				t.Errorf("seed %v: wanted album tracks %q together in order, got %q", seed, album, joined)
+			}
+		}
+	}
+}
+
+func TestShuffleArtistSpread(t *testing.T) {
+	tracks := mockArtistTracks("a", "a", "a", "a", "b", "b", "c", "c", "d", "e", "f", "g")
+	for seed := int64(0); seed < 20; seed++ {
+		r := rand.New(rand.NewSource(seed))
+		got := shuffleArtistSpread(copyTracks(tracks), r)
+		checkSameTracks(t, tracks, got)
+		var positions []int
+		for i, t := range got {
+			if t.artist == "a" {
+				positions = append(positions, i)
+			}
+		}
+		adjacent := 0
+		for i := 1; i < len(positions); i++ {
+			if positions[i]-positions[i-1] == 1 {
+				adjacent++
+			}
+		}
+		if adjacent > 1 {
+			t.Errorf("seed %v: wanted tracks by artist with a third of tracks to be spread out, got positions %v", seed, positions)
+		}
+	}
+}
+
+func TestPlaylistShuffle(t *testing.T) {
+	tracks := mockArtistTracks("a", "a", "b", "b", "c", "c", "d")
+	shuffle := func(command string) (playlist, string) {
+		var w bytes.Buffer
+		p := playlist{
+			tracks: copyTracks(tracks),
+			w:      &w,
+		}
+		p.shuffle(command)
+		return p, w.String()
+	}
+	t.Run("reproducible", func(t *testing.T) {
+		for _, mode := range []string{"", "artist", "album", "spread"} {
+			p1, out := shuffle(mode + " 1549")
+			p2, _ := shuffle(mode + " 1549")
+			switch {
+			case !strings.Contains(out, "seed 1549"):
+				t.Errorf("wanted seed in output, got %q", out)
+			case fmt.Sprint(trackDisplays(p1.tracks)) != fmt.Sprint(trackDisplays(p2.tracks)):
+				t.Errorf("%q: wanted same tracks with same seed, got %v and %v", mode, trackDisplays(p1.tracks), trackDisplays(p2.tracks))
+			case len(p1.history.undo) != 1, !p1.dirty:
+				t.Errorf("%q: wanted shuffle to be recorded", mode)
+			}
+			checkSameTracks(t, tracks, p1.tracks)
+		}
+	})
+	t.Run("constraint not kept", func(t *testing.T) {
+		var w bytes.Buffer
+		p := playlist{
+			tracks: mockArtistTracks("a", "a", "a", "b"),
+			w:      &w,
+		}
+		p.shuffle("artist 3")
+		if !strings.Contains(w.String(), "1 tracks follow a track by the same artist") {
+			t.Errorf("wanted message about constraint, got %q", w.String())
+		}
+	})
+	errorTests := []struct {
+		name    string
+		command string
+		tracks  []m3uTrack
+	}{
+		{"too few tracks", "", tracks[:1]},
+		{"bad mode", "title", tracks},
+	}
+	for _, test := range errorTests {
+		t.Run(test.name, func(t *testing.T) {
+			var w bytes.Buffer
+			p := playlist{
+				tracks: test.tracks,
+				w:      &w,
+			}
+			p.shuffle(test.command)
+			switch {
+			case !strings.HasPrefix(w.String(), "Error (shuffle)"):
+				t.Errorf("wanted error, got %q", w.String())
+			case len(p.history.undo) != 0:
+				t.Errorf("unwanted recorded change")
+			}
+		})
+	}
+}
@@ -1,12 +1,20 @@
 package main
 
-import "strings"
+import (
+	"strings"
+	"time"
+)
 
 type song struct {
-	path                 string
-	hash                 string
-	artist, album, title string
-	track                int
+	path                                  string
+	hash                                  string
+	artist, album, title                  string
+	track                                 int
+	duration                              time.Duration
+	albumArtist, composer, genre, comment string
+	year, disc                            int
+	inferred                              bool   // the fields were inferred from the path because the song tags could not be read
+	root                                  string // the name of the library root the song was read from
 }
 
 func (s song) matches(filter string, checkHash bool) bool {
@@ -14,9 +22,22 @@ func (s song) matches(filter string, checkHash bool) bool {
 	return strings.Contains(strings.ToLower(s.artist), filter) ||
 		strings.Contains(strings.ToLower(s.album), filter) ||
 		strings.Contains(strings.ToLower(s.title), filter) ||
+		strings.Contains(strings.ToLower(s.albumArtist), filter) ||
+		strings.Contains(strings.ToLower(s.composer), filter) ||
+		strings.Contains(strings.ToLower(s.genre), filter) ||
+		strings.Contains(strings.ToLower(s.comment), filter) ||
 		(checkHash && strings.Contains(s.hash, filter))
 }
 
+// sortArtist is the album artist of the song, or the artist if the album artist is not known.
+// Compilation albums have the same album artist for songs by different artists.
+func (s song) sortArtist() string {
+	if len(s.albumArtist) != 0 {
+		return s.albumArtist
+	}
+	return s.artist
+}
+
 func (s song) display() string {
 	switch {
 	case len(s.title) != 0 && len(s.artist) != 0:
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"time"
+)
+
+// songCacheVersion is incremented whenever the cached song fields change, invalidating old caches
+const songCacheVersion = 6
+
+type (
+	// songCache stores song metadata by path so unchanged files do not need to be read again
+	songCache struct {
+		entries map[string]songCacheEntry
+		removed map[string]songCacheEntry // entries of files that were not found when the songs were last read
+	}
+	songCacheEntry struct {
+		Size        int64  `json:"size"`
+		ModTime     int64  `json:"modTime"`
+		Hash        string `json:"hash,omitempty"`
+		HashKind    string `json:"hashKind,omitempty"` // the algorithm of the hash, such as md5 or sha256-audio
+		Artist      string `json:"artist,omitempty"`
+		Album       string `json:"album,omitempty"`
+		Title       string `json:"title,omitempty"`
+		Track       int    `json:"track,omitempty"`
+		Duration    int64  `json:"duration,omitempty"` // nanoseconds
+		AlbumArtist string `json:"albumArtist,omitempty"`
+		Composer    string `json:"composer,omitempty"`
+		Genre       string `json:"genre,omitempty"`
+		Comment     string `json:"comment,omitempty"`
+		Year        int    `json:"year,omitempty"`
+		Disc        int    `json:"disc,omitempty"`
+		Inferred    bool   `json:"inferred,omitempty"`
+		ErrKind     string `json:"errKind,omitempty"` // the kind of the load error of a song that was still read, such as when its tags were inferred
+		Err         string `json:"err,omitempty"`     // the message of the load error
+	}
+	songCacheFile struct {
+		Version int                       `json:"version"`
+		Entries map[string]songCacheEntry `json:"entries"`
+	}
+)
+
+func newSongCache() *songCache {
+	c := songCache{
+		entries: make(map[string]songCacheEntry),
+	}
+	return &c
+}
+
+func newSongCacheEntry(s song, info fs.FileInfo) songCacheEntry {
+	e := songCacheEntry{
+		Size:        info.Size(),
+		ModTime:     info.ModTime().UnixNano(),
+		Hash:        s.hash,
+		Artist:      s.artist,
+		Album:       s.album,
+		Title:       s.title,
+		Track:       s.track,
+		Duration:    int64(s.duration),
+		AlbumArtist: s.albumArtist,
+		Composer:    s.composer,
+		Genre:       s.genre,
+		Comment:     s.comment,
+		Year:        s.year,
+		Disc:        s.disc,
+		Inferred:    s.inferred,
+	}
+	return e
+}
+
+// song creates the song for the entry at the path
+func (e songCacheEntry) song(path string) song {
+	s := song{
+		path:        path,
+		hash:        e.Hash,
+		artist:      e.Artist,
+		album:       e.Album,
+		title:       e.Title,
+		track:       e.Track,
+		duration:    time.Duration(e.Duration),
+		albumArtist: e.AlbumArtist,
+		composer:    e.Composer,
+		genre:       e.Genre,
+		comment:     e.Comment,
+		year:        e.Year,
+		disc:        e.Disc,
+		inferred:    e.Inferred,
+	}
+	return s
+}
+
+// replaceEntries sets the entries of the songs that were read.
+// Previous entries of files that were not read are kept as removed entries.
+func (c *songCache) replaceEntries(entries map[string]songCacheEntry) {
+	c.removed = make(map[string]songCacheEntry)
+	for path, e := range c.entries {
+		if _, ok := entries[path]; !ok {
+			c.removed[path] = e
+		}
+	}
+	c.entries = entries
+}
+
+// addEntries sets the entries of the songs that were read, keeping the other entries
+func (c *songCache) addEntries(entries map[string]songCacheEntry) {
+	for path, e := range entries {
+		c.entries[path] = e
+	}
+}
+
+// removedSongs creates the songs of the removed entries by path.
+// Hashes of other kinds are not included because they cannot be compared to the hashes of the songs.
+func (c *songCache) removedSongs(hashKind string) map[string]song {
+	songs := make(map[string]song, len(c.removed))
+	for path, e := range c.removed {
+		s := e.song(path)
+		if e.HashKind != hashKind {
+			s.hash = ""
+		}
+		songs[path] = s
+	}
+	return songs
+}
+
+// lookup retrieves the cache entry for the path if the file has not changed since it was cached.
+// An entry without a hash of the kind is not used if a hash is needed.
+func (c *songCache) lookup(path string, info fs.FileInfo, hashKind string) (songCacheEntry, bool) {
+	e, ok := c.entries[path]
+	switch {
+	case !ok,
+		e.Size != info.Size(),
+		e.ModTime != info.ModTime().UnixNano(),
+		len(hashKind) != 0 && (len(e.Hash) == 0 || e.HashKind != hashKind):
+		return songCacheEntry{}, false
+	}
+	return e, true
+}
+
+// ReadFrom loads the cache entries from the reader.
+// The cache is left empty if the data is not a valid cache of the current version.
+func (c *songCache) ReadFrom(r io.Reader) (n int64, err error) {
+	c.entries = make(map[string]songCacheEntry)
+	b, err := io.ReadAll(r)
+	n = int64(len(b))
+	if err != nil {
+		return n, fmt.Errorf("reading song cache: %v", err)
+	}
+	var f songCacheFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return n, fmt.Errorf("parsing song cache: %v", err)
+	}
+	if f.Version != songCacheVersion {
+		return n, fmt.Errorf("song cache version not supported: wanted %v, got %v", songCacheVersion, f.Version)
+	}
+	if f.Entries != nil {
+		c.entries = f.Entries
+	}
+	return n, nil
+}
+
+// WriteTo saves the cache entries to the writer
+func (c songCache) WriteTo(w io.Writer) (n int64, err error) {
+	f := songCacheFile{
+		Version: songCacheVersion,
+		Entries: c.entries,
+	}
+	b, err := json.Marshal(f)
+	if err != nil {
+		return 0, fmt.Errorf("encoding song cache: %v", err)
+	}
+	n2, err := w.Write(b)
+	return int64(n2), err
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"testing/iotest"
+	"time"
+)
+
+func TestSongCacheLookup(t *testing.T) {
+	modTime := time.Date(2022, 7, 15, 1, 2, 3, 4, time.UTC)
+	c := songCache{
+		entries: map[string]songCacheEntry{
+			"a.mp3": {Size: 10, ModTime: modTime.UnixNano(), Title: "a"},
+			"b.mp3": {Size: 10, ModTime: modTime.UnixNano(), Title: "b", Hash: "123", HashKind: "md5"},
+		},
+	}
+	tests := []struct {
+		name     string
+		path     string
+		info     fstest.MapFile
+		hashKind string
+		want     bool
+	}{
+		{"missing", "c.mp3", fstest.MapFile{Data: make([]byte, 10), ModTime: modTime}, "", false},
+		{"size changed", "a.mp3", fstest.MapFile{Data: make([]byte, 11), ModTime: modTime}, "", false},
+		{"modified", "a.mp3", fstest.MapFile{Data: make([]byte, 10), ModTime: modTime.Add(time.Second)}, "", false},
+		{"unchanged", "a.mp3", fstest.MapFile{Data: make([]byte, 10), ModTime: modTime}, "", true},
+		{"hash missing", "a.mp3", fstest.MapFile{Data: make([]byte, 10), ModTime: modTime}, "md5", false},
+		{"hash cached", "b.mp3", fstest.MapFile{Data: make([]byte, 10), ModTime: modTime}, "md5", true},
+		{"other hash cached", "b.mp3", fstest.MapFile{Data: make([]byte, 10), ModTime: modTime}, "sha256-audio", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fsys := fstest.MapFS{test.path: &test.info}
+			info, err := fsys.Stat(test.path)
+			if err != nil {
+				t.Fatalf("stat: %v", err)
+			}
+			if _, got := c.lookup(test.path, info, test.hashKind); test.want != got {
+				t.Errorf("wanted cache hit: %v, got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestSongCacheEntrySong(t *testing.T) {
+	s := song{path: "a/b.mp3", hash: "f00d", artist: "x", album: "y", title: "z", track: 8, duration: time.Minute,
+		albumArtist: "v", composer: "c", genre: "g", comment: "n", year: 1999, disc: 2}
+	fsys := fstest.MapFS{"a/b.mp3": &fstest.MapFile{Data: []byte("data")}}
+	info, err := fsys.Stat("a/b.mp3")
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	e := newSongCacheEntry(s, info)
+	if want, got := int64(4), e.Size; want != got {
+		t.Errorf("sizes not equal: wanted %v, got %v", want, got)
+	}
+	if want, got := s, e.song(s.path); want != got {
+		t.Errorf("songs not equal: \n wanted: %v \n got:    %v", want, got)
+	}
+}
+
+func TestSongCacheReplaceEntries(t *testing.T) {
+	c := songCache{
+		entries: map[string]songCacheEntry{
+			"a.mp3": {Size: 1},
+			"b.mp3": {Size: 2, Artist: "x", Title: "y", Hash: "1", HashKind: "md5"},
+			"d.mp3": {Size: 5, Title: "z", Hash: "2", HashKind: "sha1"},
+		},
+	}
+	c.replaceEntries(map[string]songCacheEntry{
+		"a.mp3": {Size: 3},
+		"c.mp3": {Size: 4},
+	})
+	want := map[string]song{
+		"b.mp3": {path: "b.mp3", artist: "x", title: "y", hash: "1"},
+		"d.mp3": {path: "d.mp3", title: "z"},
+	}
+	switch got := c.removedSongs("md5"); {
+	case len(c.entries) != 2, c.entries["a.mp3"].Size != 3:
+		t.Errorf("entries not replaced: %v", c.entries)
+	case fmt.Sprint(want) != fmt.Sprint(got):
+		t.Errorf("removed songs not equal: \n wanted: %v \n got:    %v", want, got)
+	}
+}
+
+func TestSongCacheReadFrom(t *testing.T) {
+	tests := []struct {
+		name    string
+		r       io.Reader
+		want    map[string]songCacheEntry
+		wantErr bool
+	}{
+		{
+			name:    "read error",
+			r:       iotest.ErrReader(fmt.Errorf("mock read error")),
+			wantErr: true,
+		},
+		{
+			name:    "corrupt",
+			r:       strings.NewReader(fmt.Sprintf(`{"version":%d,"entries":{"a.mp3":`, songCacheVersion)),
+			wantErr: true,
+		},
+		{
+			name:    "old version",
+			r:       strings.NewReader(fmt.Sprintf(`{"version":%d,"entries":{"a.mp3":{"size":3}}}`, songCacheVersion-1)),
+			wantErr: true,
+		},
+		{
+			name: "no entries",
+			r:    strings.NewReader(fmt.Sprintf(`{"version":%d}`, songCacheVersion)),
+			want: map[string]songCacheEntry{},
+		},
+		{
+			name: "ok",
+			r:    strings.NewReader(fmt.Sprintf(`{"version":%d,"entries":{"a.mp3":{"size":3,"modTime":7,"title":"t","track":2,"duration":9}}}`, songCacheVersion)),
+			want: map[string]songCacheEntry{
+				"a.mp3": {Size: 3, ModTime: 7, Title: "t", Track: 2, Duration: 9},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := songCache{
+				entries: map[string]songCacheEntry{"old.mp3": {}},
+			}
+			_, err := c.ReadFrom(test.r)
+			switch {
+			case test.wantErr:
+				if err == nil {
+					t.Error("wanted error")
+				}
+				if len(c.entries) != 0 {
+					t.Errorf("wanted cache to be empty after error, got %v", c.entries)
+				}
+			case err != nil:
+				t.Errorf("unwanted error: %v", err)
+			case fmt.Sprint(test.want) != fmt.Sprint(c.entries):
+				t.Errorf("entries not equal: \n wanted: %v \n got:    %v", test.want, c.entries)
+			}
+		})
+	}
+}
+
+func TestSongCacheWriteTo(t *testing.T) {
+	c := songCache{
+		entries: map[string]songCacheEntry{
+			"a.mp3": {Size: 3, ModTime: 7, Artist: "x", Album: "y", Title: "z", Track: 2, Hash: "f00d", Duration: 9},
+		},
+	}
+	var buf bytes.Buffer
+	n, err := c.WriteTo(&buf)
+	switch {
+	case err != nil:
+		t.Fatalf("unwanted error: %v", err)
+	case n != int64(buf.Len()):
+		t.Errorf("wanted %v bytes to be written, got %v", buf.Len(), n)
+	}
+	c2 := newSongCache()
+	if _, err := c2.ReadFrom(&buf); err != nil {
+		t.Fatalf("reading written cache: %v", err)
+	}
+	if want, got := fmt.Sprint(c.entries), fmt.Sprint(c2.entries); want != got {
+		t.Errorf("cache not equal after round trip: \n wanted: %v \n got:    %v", want, got)
+	}
+}
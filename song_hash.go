@@ -0,0 +1,202 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"io"
+	"sort"
+	"strings"
+)
+
+// songHashes create the hashes that can identify song files, by name
+var songHashes = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"xxhash": func() hash.Hash { return newXXHash64() }, // fast, but not cryptographic
+}
+
+// audioSectionFuncs find the audio of song files by extension, which is hashed when only the audio is hashed
+var audioSectionFuncs = map[string]func(rs io.ReadSeeker, size int64) ([]fileSection, error){
+	".mp3":  mp3AudioSections,
+	".m4a":  mp4AudioSections,
+	".m4b":  mp4AudioSections,
+	".m4p":  mp4AudioSections,
+	".flac": flacAudioSections,
+}
+
+// songHasher streams the contents of song files into a hash
+type songHasher struct {
+	name      string
+	newHash   func() hash.Hash
+	audioOnly bool // only hash the audio, so retagged copies of songs have the same hash
+}
+
+// fileSection is the byte range of a file from start up to end
+type fileSection struct {
+	start, end int64
+}
+
+// hashColumnFormat formats the hashes of the songs in a column as wide as an md5sum or the longest hash
+func hashColumnFormat(songs []song) string {
+	width := 32
+	for _, s := range songs {
+		if width < len(s.hash) {
+			width = len(s.hash)
+		}
+	}
+	return fmt.Sprintf("%%%dv    ", width)
+}
+
+// newSongHasher creates a hasher for the hash with the name
+func newSongHasher(name string, audioOnly bool) (*songHasher, error) {
+	name = strings.ToLower(name)
+	newHash, ok := songHashes[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown hash %q (wanted one of %v)", name, songHashNames())
+	}
+	h := songHasher{
+		name:      name,
+		newHash:   newHash,
+		audioOnly: audioOnly,
+	}
+	return &h, nil
+}
+
+// songHashNames lists the sorted names of the hashes
+func songHashNames() string {
+	names := make([]string, 0, len(songHashes))
+	for name := range songHashes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// kind identifies the hashes the hasher creates, such as md5 or sha256-audio
+func (h songHasher) kind() string {
+	if h.audioOnly {
+		return h.name + "-audio"
+	}
+	return h.name
+}
+
+// hash computes the hex encoded hash of the file with the extension
+func (h songHasher) hash(rs io.ReadSeeker, ext string) (string, error) {
+	size, err := rs.Seek(0, io.SeekEnd)
+	if err != nil {
+		return "", fmt.Errorf("seeking to end of file: %v", err)
+	}
+	sections := []fileSection{{0, size}}
+	if h.audioOnly {
+		if audio, err := audioSections(rs, ext, size); err == nil && len(audio) != 0 {
+			sections = audio
+		}
+	}
+	hh := h.newHash()
+	for _, s := range sections {
+		if _, err := rs.Seek(s.start, io.SeekStart); err != nil {
+			return "", fmt.Errorf("seeking to audio: %v", err)
+		}
+		if _, err := io.CopyN(hh, rs, s.end-s.start); err != nil {
+			return "", fmt.Errorf("hashing file: %v", err)
+		}
+	}
+	return fmt.Sprintf("%x", hh.Sum(nil)), nil
+}
+
+// audioSections finds the parts of the file that are not metadata.
+// The whole file is audio if the format is not known.
+func audioSections(rs io.ReadSeeker, ext string, size int64) ([]fileSection, error) {
+	if f, ok := audioSectionFuncs[strings.ToLower(ext)]; ok {
+		return f(rs, size)
+	}
+	return []fileSection{{0, size}}, nil
+}
+
+// wholeFileAudioExtensions are the extensions of songs that are hashed whole when only the audio is hashed because their audio cannot be found
+func wholeFileAudioExtensions(extensions []string) []string {
+	var whole []string
+	for _, ext := range extensions {
+		if _, ok := audioSectionFuncs[strings.ToLower(ext)]; !ok {
+			whole = append(whole, ext)
+		}
+	}
+	return whole
+}
+
+// mp3AudioSections excludes the ID3v2 tag at the start and the ID3v1 tag at the end of the file
+func mp3AudioSections(rs io.ReadSeeker, size int64) ([]fileSection, error) {
+	s := fileSection{0, size}
+	var h [10]byte
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(rs, h[:]); err == nil && string(h[:3]) == "ID3" {
+		s.start = 10 + (int64(h[6]&0x7F)<<21 | int64(h[7]&0x7F)<<14 | int64(h[8]&0x7F)<<7 | int64(h[9]&0x7F)) // syncsafe integer
+		if h[5]&0x10 != 0 {                                                                                   // footer
+			s.start += 10
+		}
+	}
+	const id3v1Size = 128
+	if size-id3v1Size >= s.start {
+		if _, err := rs.Seek(size-id3v1Size, io.SeekStart); err != nil {
+			return nil, err
+		}
+		if _, err := io.ReadFull(rs, h[:3]); err == nil && string(h[:3]) == "TAG" {
+			s.end = size - id3v1Size
+		}
+	}
+	if s.start > s.end {
+		return nil, fmt.Errorf("ID3v2 tag larger than file")
+	}
+	return []fileSection{s}, nil
+}
+
+// mp4AudioSections are the contents of the top-level media data atoms
+func mp4AudioSections(rs io.ReadSeeker, size int64) ([]fileSection, error) {
+	var sections []fileSection
+	for pos := int64(0); pos < size; {
+		start, end, err := findMP4Atom(rs, pos, size, "mdat")
+		if err != nil {
+			break
+		}
+		sections = append(sections, fileSection{start, end})
+		pos = end
+	}
+	if len(sections) == 0 {
+		return nil, fmt.Errorf("no mp4 media data found")
+	}
+	return sections, nil
+}
+
+// flacAudioSections excludes the metadata blocks at the start of the file
+func flacAudioSections(rs io.ReadSeeker, size int64) ([]fileSection, error) {
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	var h [4]byte
+	if _, err := io.ReadFull(rs, h[:]); err != nil || string(h[:]) != "fLaC" {
+		return nil, fmt.Errorf("no flac marker found")
+	}
+	pos := int64(4)
+	for last := false; !last; {
+		if _, err := rs.Seek(pos, io.SeekStart); err != nil {
+			return nil, err
+		}
+		if _, err := io.ReadFull(rs, h[:]); err != nil {
+			return nil, fmt.Errorf("reading flac metadata block header: %v", err)
+		}
+		last = h[0]&0x80 != 0
+		h[0] = 0
+		pos += 4 + int64(binary.BigEndian.Uint32(h[:]))
+	}
+	if pos > size {
+		return nil, fmt.Errorf("flac metadata larger than file")
+	}
+	return []fileSection{{pos, size}}, nil
+}
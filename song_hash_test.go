@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestNewSongHasher(t *testing.T) {
+	tests := []struct {
+		name      string
+		audioOnly bool
+		wantKind  string
+		wantErr   bool
+	}{
+		{"md5", false, "md5", false},
+		{"SHA256", true, "sha256-audio", false},
+		{"xxhash", false, "xxhash", false},
+		{"crc32", false, "", true},
+	}
+	for _, test := range tests {
+		h, err := newSongHasher(test.name, test.audioOnly)
+		switch {
+		case test.wantErr:
+			if err == nil {
+				t.Errorf("wanted error creating %q hasher", test.name)
+			}
+		case err != nil:
+			t.Errorf("unwanted error creating %q hasher: %v", test.name, err)
+		case test.wantKind != h.kind():
+			t.Errorf("hash kinds not equal: wanted %q, got %q", test.wantKind, h.kind())
+		}
+	}
+}
+
+func TestSongHasherHash(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"md5", "abc", "900150983cd24fb0d6963f7d28e17f72"},
+		{"sha1", "abc", "a9993e364706816aba3e25717850c26c9cd0d89d"},
+		{"sha256", "abc", "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{"xxhash", "abc", "44bc2cf5ad770999"},
+	}
+	for _, test := range tests {
+		h, err := newSongHasher(test.name, false)
+		if err != nil {
+			t.Fatalf("creating hasher: %v", err)
+		}
+		got, err := h.hash(bytes.NewReader([]byte(test.data)), ".mp3")
+		switch {
+		case err != nil:
+			t.Errorf("unwanted error hashing with %v: %v", test.name, err)
+		case test.want != got:
+			t.Errorf("%v hashes not equal: wanted %v, got %v", test.name, test.want, got)
+		}
+	}
+}
+
+func TestSongHasherHashAudioOnly(t *testing.T) {
+	audio := bytes.Repeat([]byte{0xFF, 0xFB, 0x90, 0x00}, 100)
+	id3v2 := func(size byte) []byte {
+		tag := []byte{'I', 'D', '3', 3, 0, 0, 0, 0, 0, size}
+		return append(tag, make([]byte, size)...)
+	}
+	id3v1 := append([]byte("TAG"), make([]byte, 125)...)
+	join := func(parts ...[]byte) []byte {
+		return bytes.Join(parts, nil)
+	}
+	files := [][]byte{
+		audio,
+		join(id3v2(5), audio),
+		join(id3v2(20), audio, id3v1),
+	}
+	hashes := func(audioOnly bool) []string {
+		h, err := newSongHasher("md5", audioOnly)
+		if err != nil {
+			t.Fatalf("creating hasher: %v", err)
+		}
+		var hashes []string
+		for _, f := range files {
+			s, err := h.hash(bytes.NewReader(f), ".MP3")
+			if err != nil {
+				t.Fatalf("unwanted error: %v", err)
+			}
+			hashes = append(hashes, s)
+		}
+		return hashes
+	}
+	if got := hashes(true); got[0] != got[1] || got[0] != got[2] {
+		t.Errorf("wanted retagged songs to have the same audio hash: %v", got)
+	}
+	if got := hashes(false); got[0] == got[1] || got[0] == got[2] || got[1] == got[2] {
+		t.Errorf("wanted retagged songs to have different file hashes: %v", got)
+	}
+}
+
+func TestAudioSections(t *testing.T) {
+	flacBlock := func(last bool, size byte) []byte {
+		h := []byte{0, 0, 0, size}
+		if last {
+			h[0] = 0x80
+		}
+		return append(h, make([]byte, size)...)
+	}
+	tests := []struct {
+		name    string
+		ext     string
+		data    []byte
+		want    string
+		wantErr bool
+	}{
+		{"unknown format", ".ogg", []byte("OggS data"), "[{0 9}]", false},
+		{"mp3 without tags", ".mp3", []byte("audio"), "[{0 5}]", false},
+		{"mp3 with ID3v2", ".mp3", append([]byte{'I', 'D', '3', 4, 0, 0, 0, 0, 0, 2, 'x', 'y'}, "audio"...), "[{12 17}]", false},
+		{"mp3 with ID3v2 footer", ".mp3", append([]byte{'I', 'D', '3', 4, 0, 0x10, 0, 0, 0, 0}, make([]byte, 15)...), "[{20 25}]", false},
+		{"mp3 ID3v2 too large", ".mp3", []byte{'I', 'D', '3', 4, 0, 0, 0, 0, 1, 0}, "", true},
+		{"mp3 with ID3v1", ".mp3", append([]byte("audio"), append([]byte("TAG"), make([]byte, 125)...)...), "[{0 5}]", false},
+		{"mp4", ".m4a", bytes.Join([][]byte{mockMP4Atom("ftyp"), mockMP4Atom("mdat", []byte("ab")), mockMP4Atom("moov"), mockMP4Atom("mdat", []byte("c"))}, nil), "[{16 18} {34 35}]", false},
+		{"mp4 without media data", ".m4b", mockMP4Atom("moov"), "", true},
+		{"flac", ".flac", bytes.Join([][]byte{[]byte("fLaC"), flacBlock(false, 3), flacBlock(true, 1), []byte("audio")}, nil), "[{16 21}]", false},
+		{"flac metadata too large", ".flac", bytes.Join([][]byte{[]byte("fLaC"), {0x80, 0, 0, 9}}, nil), "", true},
+		{"not flac", ".flac", []byte("data"), "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := audioSections(bytes.NewReader(test.data), test.ext, int64(len(test.data)))
+			switch {
+			case test.wantErr:
+				if err == nil {
+					t.Errorf("wanted error")
+				}
+			case err != nil:
+				t.Errorf("unwanted error: %v", err)
+			case test.want != fmt.Sprint(got):
+				t.Errorf("audio sections not equal: wanted %v, got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestHashColumnFormat(t *testing.T) {
+	tests := []struct {
+		songs []song
+		want  string
+	}{
+		{nil, "%32v    "},
+		{[]song{{hash: "tiny"}}, "%32v    "},
+		{[]song{{hash: "abc"}, {hash: fmt.Sprintf("%064d", 0)}}, "%64v    "},
+	}
+	for _, test := range tests {
+		if got := hashColumnFormat(test.songs); test.want != got {
+			t.Errorf("hash column formats not equal: wanted %q, got %q", test.want, got)
+		}
+	}
+}
+
+func TestWholeFileAudioExtensions(t *testing.T) {
+	want := "[.ogg .dsf]"
+	if got := fmt.Sprint(wholeFileAudioExtensions(songExtensions)); want != got {
+		t.Errorf("extensions not equal: wanted %v, got %v", want, got)
+	}
+}
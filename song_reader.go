@@ -1,132 +1,432 @@
 package main
 
 import (
-	"crypto/md5"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
+	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/dhowden/tag"
 )
 
+// songExtensions are the extensions of the files github.com/dhowden/tag can read tags from.
+// ALAC songs are stored in .m4a files.
+var songExtensions = []string{".mp3", ".m4a", ".m4b", ".m4p", ".flac", ".ogg", ".dsf"}
+
 type songReader struct {
 	fsys         fs.FS
-	addHash      bool
+	roots        []libraryRoot // the folders songs are read from, fsys is read if empty
+	hasher       *songHasher   // hashes are not loaded if nil
 	loadThreads  int
 	pathSuffixes []string
+	cache        *songCache
+	filter       *pathFilter    // chooses the files to read, every file with a song extension is read if nil
+	templates    []pathTemplate // infer the metadata of songs without readable tags from their paths, files with tag errors are skipped if empty
+	progressMode string         // how the progress of reading songs is displayed, one of the progressModes, lines if empty
+	followLinks  bool           // descend into folders that are symbolic links, reading songs by their canonical paths once
+}
+
+// songFile is the path of a song file in a library root
+type songFile struct {
+	root      libraryRoot
+	path      string
+	canonical string // the path of the song with symbolic links resolved, if they are followed
+}
+
+// songPath is the path of the song relative to the working directory
+func (f songFile) songPath() string {
+	if len(f.canonical) != 0 {
+		return f.canonical
+	}
+	return f.root.songPath(f.path)
+}
+
+// readSongs reads the songs in the library roots, returning the problems of the files that could not be read.
+// If the context is canceled, the songs that were read are returned with an error.
+func (sr songReader) readSongs(ctx context.Context, w io.Writer) ([]song, []loadError, error) {
+	var files []songFile
+	var walkErrors []loadError
+	canonicalPaths := make(map[string]bool) // the folders and files that were walked, to skip loops and songs linked from several folders
+	for _, root := range sr.libraryRoots() {
+		if err := fs.WalkDir(root.fsys, ".", sr.walkDir(ctx, root, &files, &walkErrors, canonicalPaths)); err != nil {
+			return nil, nil, fmt.Errorf("walking directory: %w", err)
+		}
+	}
+	songs, loadErrors, err := sr.readPaths(ctx, w, files)
+	return songs, append(walkErrors, loadErrors...), err
 }
 
-func (sr songReader) readSongs(w io.Writer) ([]song, error) {
-	var paths []string
-	if err := fs.WalkDir(sr.fsys, ".", sr.walkDir(&paths, w)); err != nil {
-		return nil, fmt.Errorf("walking directory: %v", err)
+// libraryRoots are the folders songs are read from, the file system if no roots are set
+func (sr songReader) libraryRoots() []libraryRoot {
+	if len(sr.roots) == 0 {
+		return []libraryRoot{{fsys: sr.fsys}}
 	}
-	return sr.readPaths(w, paths)
+	return sr.roots
 }
 
-func (sr songReader) readPaths(w io.Writer, paths []string) ([]song, error) {
-	if len(paths) == 0 {
-		return nil, nil
+// readPaths reads the song files with the load threads.
+// Files that cannot be read are reported and skipped.
+// Canceling the context stops the load threads and returns the songs that were read with the error of the context.
+func (sr songReader) readPaths(ctx context.Context, w io.Writer, files []songFile) ([]song, []loadError, error) {
+	if len(files) == 0 {
+		return nil, nil, nil
 	}
-	pathsC := make(chan string, len(paths))
-	for _, path := range paths {
-		pathsC <- path
+	filesC := make(chan songFile, len(files))
+	for _, f := range files {
+		filesC <- f
 	}
-	songs := make([]song, 0, len(paths))
+	close(filesC)
+	songs := make([]song, 0, len(files))
+	cacheEntries := make(map[string]songCacheEntry, len(files))
+	var loadErrors []loadError
+	reused, inferred := 0, 0
 	resultsC := make(chan readResult)
 	if sr.loadThreads < 1 {
 		sr.loadThreads = 1
 	}
+	var wg sync.WaitGroup
+	wg.Add(sr.loadThreads)
 	for i := 0; i < sr.loadThreads; i++ {
 		go func() {
-			for path := range pathsC {
-				resultsC <- sr.readSong(path)
+			defer wg.Done()
+			for f := range filesC {
+				rr := sr.readSong(ctx, f)
+				if rr.song != nil {
+					rr.song.root = f.root.name // not cached, the same folder can be given by different names
+				}
+				select {
+				case resultsC <- rr:
+				case <-ctx.Done():
+					return
+				}
 			}
 		}()
 	}
-	dc := digitCount(len(paths))
-	cursorUp := "\033[1A"
-	format := fmt.Sprintf("%v> reading songs: [%%%dd/%d]\n", cursorUp, dc, len(paths))
+	go func() {
+		wg.Wait()
+		close(resultsC) // all load threads are done
+	}()
+	progress := newProgressReporter(sr.progressMode, w, len(files))
 	resultID := 0
 	start := time.Now()
-	fmt.Fprintf(w, "\n")
 	for rr := range resultsC {
+		if rr.err != nil && (rr.song != nil || ctx.Err() == nil) {
+			loadErrors = append(loadErrors, loadError{path: rr.path, kind: rr.errKind, err: rr.err.Error()})
+		}
 		switch {
-		case rr.tagErr:
-			fmt.Fprintf(w, "%v> parsing tags for %v: %v\n\n", cursorUp, rr.path, rr.err)
-		case rr.err != nil:
-			return nil, rr.err
+		case rr.song == nil && rr.err != nil && ctx.Err() != nil:
+			// NOOP: the read was stopped by the cancellation
+		case rr.song == nil:
+			progress.message(fmt.Sprintf("> %v: %v", rr.path, rr.err))
 		default:
 			songs = append(songs, *rr.song)
+			cacheEntries[rr.path] = rr.cacheEntry
+			if rr.cached {
+				reused++
+			}
+			if rr.song.inferred {
+				inferred++
+			}
 		}
 		resultID++
-		fmt.Fprintf(w, format, resultID)
-		if resultID == len(paths) {
-			break
-		}
+		progress.update(resultID)
 	}
+	progress.finish()
 	d := time.Since(start).Seconds()
-	fmt.Fprintf(w, "> loaded %v songs with %v errors in in %0.1f seconds\n", len(songs), len(paths)-len(songs), d)
-	return songs, nil
+	if err := ctx.Err(); err != nil {
+		if sr.cache != nil {
+			sr.cache.addEntries(cacheEntries) // keep the songs that were read for the next load
+		}
+		fmt.Fprintf(w, "> canceled after loading %v of %v songs in %0.1f seconds\n", len(songs), len(files), d)
+		return songs, loadErrors, fmt.Errorf("loading songs: %w", err)
+	}
+	cacheSummary := ""
+	if sr.cache != nil {
+		sr.cache.replaceEntries(cacheEntries) // drop entries for files that no longer exist
+		cacheSummary = fmt.Sprintf(" (%v reused from cache, %v read)", reused, len(songs)-reused)
+	}
+	inferredSummary := ""
+	if inferred != 0 {
+		inferredSummary = fmt.Sprintf(", %v with tags inferred from file paths,", inferred)
+	}
+	fmt.Fprintf(w, "> loaded %v songs%v%v with %v errors in %0.1f seconds\n", len(songs), cacheSummary, inferredSummary, len(loadErrors), d)
+	if len(songs) != 0 {
+		fmt.Fprintf(w, "> formats: %v\n", formatCounts(songs))
+	}
+	if len(loadErrors) != 0 {
+		fmt.Fprintf(w, "> use the errors command to list the files with errors\n")
+	}
+	return songs, loadErrors, nil
 }
 
+// validPath determines if the path ends with one of the path suffixes, ignoring case
 func (sr songReader) validPath(p string) bool {
+	p = strings.ToLower(p)
 	for _, suffix := range sr.pathSuffixes {
-		if strings.HasSuffix(p, suffix) {
+		if strings.HasSuffix(p, strings.ToLower(suffix)) {
 			return true
 		}
 	}
 	return false
 }
 
-func (sr songReader) walkDir(paths *[]string, w io.Writer) func(path string, d fs.DirEntry, err error) error {
-	return func(path string, d fs.DirEntry, err error) error {
+// parseSongExtensions reads the comma separated extensions of song files, such as mp3,.flac
+func parseSongExtensions(text string) ([]string, error) {
+	var extensions []string
+	for _, f := range strings.Split(text, ",") {
+		ext := strings.ToLower(strings.TrimSpace(f))
+		if len(ext) == 0 {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		supported := false
+		for _, e := range songExtensions {
+			if ext == e {
+				supported = true
+				break
+			}
+		}
+		if !supported {
+			return nil, fmt.Errorf("song extension %q not supported (wanted some of %v)", f, strings.Join(songExtensions, ","))
+		}
+		extensions = append(extensions, ext)
+	}
+	if len(extensions) == 0 {
+		return nil, fmt.Errorf("no song extensions")
+	}
+	return extensions, nil
+}
+
+// formatCounts lists the number of songs by extension, such as ".flac: 2, .mp3: 10"
+func formatCounts(songs []song) string {
+	counts := make(map[string]int)
+	for _, s := range songs {
+		ext := strings.ToLower(filepath.Ext(s.path))
+		counts[ext]++
+	}
+	extensions := make([]string, 0, len(counts))
+	for ext := range counts {
+		extensions = append(extensions, ext)
+	}
+	sort.Strings(extensions)
+	parts := make([]string, len(extensions))
+	for i, ext := range extensions {
+		parts[i] = fmt.Sprintf("%v: %v", ext, counts[ext])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// walkDir collects the song files in the root, and the errors of the folders that could not be read.
+// Excluded folders are skipped without reading them.
+// The patterns in the ignore file of each folder exclude the files and folders in it.
+// If links are followed, folders and files that were already walked by their canonical paths are skipped, which stops loops.
+func (sr songReader) walkDir(ctx context.Context, root libraryRoot, files *[]songFile, loadErrors *[]loadError, canonicalPaths map[string]bool) fs.WalkDirFunc {
+	var excludes []pathPattern
+	if sr.filter != nil {
+		excludes = append(excludes, sr.filter.excludes...)
+	}
+	var walk fs.WalkDirFunc
+	walk = func(path string, d fs.DirEntry, err error) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err != nil {
+			*loadErrors = append(*loadErrors, loadError{path: root.songPath(path), kind: "open", err: err.Error()})
+			return nil
+		}
+		if sr.followLinks && d.Type()&fs.ModeSymlink != 0 {
+			info, err := fs.Stat(root.fsys, path)
+			if err != nil {
+				*loadErrors = append(*loadErrors, loadError{path: root.songPath(path), kind: "open", err: err.Error()})
+				return nil
+			}
+			if info.IsDir() {
+				if matchPathPatterns(excludes, path, true) {
+					return nil
+				}
+				return fs.WalkDir(root.fsys, path, walk) // the link is walked as a folder
+			}
+		}
+		var canonical string
+		if sr.followLinks && (d.IsDir() || sr.validPath(path)) {
+			canonical, err = root.canonicalPath(path)
+			if err != nil {
+				*loadErrors = append(*loadErrors, loadError{path: root.songPath(path), kind: "open", err: err.Error()})
+				return nil
+			}
+		}
 		switch {
-		case err != nil, d.IsDir(), !sr.validPath(path):
+		case path != "." && matchPathPatterns(excludes, path, d.IsDir()):
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+		case len(canonical) != 0 && canonicalPaths[canonical]:
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+		case d.IsDir():
+			if len(canonical) != 0 {
+				canonicalPaths[canonical] = true
+			}
+			patterns, err := readIgnoreFile(root.fsys, path)
+			if err != nil {
+				*loadErrors = append(*loadErrors, loadError{path: root.songPath(path), kind: "open", err: fmt.Sprintf("reading %v: %v", ignoreFileName, err)})
+			}
+			excludes = append(excludes, patterns...) // later patterns take precedence, so files in subfolders override their parents
+		case !sr.validPath(path), sr.filter != nil && !sr.filter.included(path):
 			// NOOP
 		default:
-			*paths = append(*paths, path)
+			if len(canonical) != 0 {
+				canonicalPaths[canonical] = true
+			}
+			*files = append(*files, songFile{root, path, canonical})
 		}
 		return nil
 	}
+	return walk
 }
 
 type readResult struct {
-	song   *song
-	err    error
-	path   string
-	tagErr bool
+	song       *song
+	err        error
+	errKind    string // the kind of load error, the song is still read if tags are inferred
+	path       string
+	cached     bool
+	cacheEntry songCacheEntry
 }
 
-func (sr songReader) readSong(path string) readResult {
-	f, err := sr.fsys.Open(path)
+// readSong reads the song of the file.
+// The path of the song is relative to the working directory.
+func (sr songReader) readSong(ctx context.Context, sf songFile) readResult {
+	path := sf.songPath()
+	if err := ctx.Err(); err != nil {
+		return readResult{err: err, errKind: "open", path: path}
+	}
+	f, err := sf.root.fsys.Open(sf.path)
 	if err != nil {
-		return readResult{err: err, path: path}
+		return readResult{err: err, errKind: "open", path: path}
 	}
 	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return readResult{err: err, errKind: "open", path: path}
+	}
+	if sr.cache != nil {
+		if e, ok := sr.cache.lookup(path, info, sr.hashKind()); ok {
+			s := e.song(path)
+			if sr.hasher == nil {
+				s.hash = ""
+			}
+			rr := readResult{song: &s, path: path, cached: true, cacheEntry: e}
+			if len(e.Err) != 0 {
+				rr.err, rr.errKind = errors.New(e.Err), e.ErrKind // report the problem with the file again
+			}
+			return rr
+		}
+	}
 	rs := f.(io.ReadSeeker)
 	m, err := tag.ReadFrom(rs)
 	if err != nil {
-		return readResult{err: err, path: path, tagErr: true}
+		errKind := "tags"
+		if errors.Is(err, tag.ErrNoTagsFound) {
+			errKind = "format"
+		}
+		err = fmt.Errorf("parsing tags: %w", err)
+		if len(sr.templates) == 0 {
+			return readResult{err: err, errKind: errKind, path: path}
+		}
+		rr := sr.readUntaggedSong(ctx, path, rs, info)
+		if rr.err == nil {
+			rr.err, rr.errKind = fmt.Errorf("%w (tags inferred from path)", err), errKind
+			rr.cacheEntry.Err, rr.cacheEntry.ErrKind = rr.err.Error(), rr.errKind
+		}
+		return rr
 	}
 	track, _ := m.Track()
+	disc, _ := m.Disc()
+	s := song{
+		path:        path,
+		album:       m.Album(),
+		artist:      m.Artist(),
+		title:       m.Title(),
+		track:       track,
+		albumArtist: m.AlbumArtist(),
+		composer:    m.Composer(),
+		genre:       m.Genre(),
+		comment:     m.Comment(),
+		year:        m.Year(),
+		disc:        disc,
+	}
+	switch m.FileType() {
+	case tag.MP3:
+		s.duration, _ = mp3Duration(rs)
+	case tag.M4A, tag.M4B, tag.M4P, tag.ALAC:
+		s.duration, _ = mp4Duration(rs)
+	}
+	if len(s.title) == 0 && len(s.artist) == 0 {
+		inferSongFields(&s, sr.templates)
+	}
+	return sr.hashSong(ctx, s, rs, info)
+}
+
+// readUntaggedSong creates a song with metadata inferred from its path.
+// The duration is read from the file if the extension is known.
+func (sr songReader) readUntaggedSong(ctx context.Context, path string, rs io.ReadSeeker, info fs.FileInfo) readResult {
 	s := song{
-		path:   path,
-		album:  m.Album(),
-		artist: m.Artist(),
-		title:  m.Title(),
-		track:  track,
-	}
-	if sr.addHash {
-		rs.Seek(0, io.SeekStart)
-		b, err := io.ReadAll(rs)
+		path: path,
+	}
+	inferSongFields(&s, sr.templates)
+	rs.Seek(0, io.SeekStart)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mp3":
+		s.duration, _ = mp3Duration(rs)
+	case ".m4a", ".m4b", ".m4p":
+		s.duration, _ = mp4Duration(rs)
+	}
+	return sr.hashSong(ctx, s, rs, info)
+}
+
+// hashSong adds the hash of the file to the song if hashes are loaded
+// Hashing stops if the context is canceled.
+func (sr songReader) hashSong(ctx context.Context, s song, rs io.ReadSeeker, info fs.FileInfo) readResult {
+	if sr.hasher != nil {
+		h, err := sr.hasher.hash(contextReadSeeker{ctx, rs}, filepath.Ext(s.path))
 		if err != nil {
-			return readResult{err: err, path: path}
+			return readResult{err: err, errKind: "hash", path: s.path}
 		}
-		h := md5.Sum(b)
-		s.hash = fmt.Sprintf("%x", h)
+		s.hash = h
+	}
+	e := newSongCacheEntry(s, info)
+	e.HashKind = sr.hashKind()
+	return readResult{song: &s, path: s.path, cacheEntry: e}
+}
+
+// hashKind identifies the hashes of the songs, empty if hashes are not loaded
+func (sr songReader) hashKind() string {
+	if sr.hasher == nil {
+		return ""
+	}
+	return sr.hasher.kind()
+}
+
+// contextReadSeeker stops reading when the context is done
+type contextReadSeeker struct {
+	ctx context.Context
+	io.ReadSeeker
+}
+
+// Read reads from the reader if the context is not done
+func (r contextReadSeeker) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
 	}
-	return readResult{song: &s}
+	return r.ReadSeeker.Read(p)
 }
@@ -1,9 +1,19 @@
 package main
 
 import (
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
 	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
 	"testing/fstest"
+	"time"
 )
 
 func TestSongReaderValidPath(t *testing.T) {
@@ -19,7 +29,9 @@ func TestSongReaderValidPath(t *testing.T) {
 		{"basic mp3", []string{".mp3"}, "song.mp3", true},
 		{"basic mp3", []string{".mp3"}, "song.doc", false},
 		{"m4a", []string{".mp3", ".m4a", ".ogg"}, "song.m4a", true},
-		{"case-sensitive", []string{".MP3"}, "song.mp3", false},
+		{"case-insensitive suffix", []string{".MP3"}, "song.mp3", true},
+		{"case-insensitive path", []string{".mp3"}, "SONG.MP3", true},
+		{"flac", songExtensions, "a/b.flac", true},
 		{"must be suffix 1", []string{".mp3"}, ".mp3.docx", false},
 		{"must be suffix 2", []string{".mp3"}, "secrets.mp3.doc", false},
 	}
@@ -33,6 +45,46 @@ func TestSongReaderValidPath(t *testing.T) {
 	}
 }
 
+func TestParseSongExtensions(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    string
+		wantErr bool
+	}{
+		{".mp3,.m4a", "[.mp3 .m4a]", false},
+		{"FLAC, ogg,,dsf", "[.flac .ogg .dsf]", false},
+		{".wav", "", true},
+		{"", "", true},
+		{" , ", "", true},
+	}
+	for _, test := range tests {
+		got, err := parseSongExtensions(test.text)
+		switch {
+		case test.wantErr:
+			if err == nil {
+				t.Errorf("wanted error parsing %q", test.text)
+			}
+		case err != nil:
+			t.Errorf("unwanted error parsing %q: %v", test.text, err)
+		case test.want != fmt.Sprint(got):
+			t.Errorf("extensions of %q not equal: wanted %v, got %v", test.text, test.want, got)
+		}
+	}
+}
+
+func TestFormatCounts(t *testing.T) {
+	songs := []song{
+		{path: "a.mp3"},
+		{path: "b/c.FLAC"},
+		{path: "d.Mp3"},
+		{path: "e.flac"},
+		{path: "f.m4a"},
+	}
+	if want, got := ".flac: 2, .m4a: 1, .mp3: 2", formatCounts(songs); want != got {
+		t.Errorf("format counts not equal: wanted %q, got %q", want, got)
+	}
+}
+
 func TestSongReaderReadSongs(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -80,25 +132,28 @@ func TestSongReaderReadSongs(t *testing.T) {
 			},
 			want: []song{
 				{
-					path:   "a.mp3",
-					artist: emptyMp3Artist,
-					album:  emptyMp3Album,
-					title:  emptyMp3Title,
-					track:  1549,
+					path:     "a.mp3",
+					artist:   emptyMp3Artist,
+					album:    emptyMp3Album,
+					title:    emptyMp3Title,
+					track:    1549,
+					duration: emptyMp3Duration,
 				},
 				{
-					path:   "b/c/d.mp3",
-					artist: "Beck      ",
-					album:  "Guero     ",
-					title:  "E-Pro     ",
-					track:  2,
+					path:     "b/c/d.mp3",
+					artist:   "Beck      ",
+					album:    "Guero     ",
+					title:    "E-Pro     ",
+					track:    2,
+					duration: emptyMp3Duration,
 				},
 				{
-					path:   "b/c/e.mp3",
-					artist: "Eagles Of ",
-					album:  "Peace Love",
-					title:  "I Only Wan",
-					track:  1,
+					path:     "b/c/e.mp3",
+					artist:   "Eagles Of ",
+					album:    "Peace Love",
+					title:    "I Only Wan",
+					track:    1,
+					duration: emptyMp3Duration,
 				},
 			},
 		},
@@ -117,11 +172,45 @@ func TestSongReaderReadSongs(t *testing.T) {
 			},
 			want: []song{
 				{
-					path:   "c.mp3",
-					artist: emptyMp3Artist,
-					album:  emptyMp3Album,
-					title:  emptyMp3Title,
-					track:  1549,
+					path:     "c.mp3",
+					artist:   emptyMp3Artist,
+					album:    emptyMp3Album,
+					title:    emptyMp3Title,
+					track:    1549,
+					duration: emptyMp3Duration,
+				},
+			},
+		},
+		{
+			name: "bad song with path templates (should be inferred)",
+			sr: songReader{
+				pathSuffixes: []string{".mp3"},
+				templates: []pathTemplate{
+					*mustPathTemplate(t, "{artist}/{album}/{track} - {title}"),
+					*mustPathTemplate(t, "{title}"),
+				},
+				fsys: fstest.MapFS{
+					"Beck/Guero/04 - Missing.mp3": &fstest.MapFile{
+						Data: []byte("UNKNOWN"),
+					},
+					"bad.mp3": &fstest.MapFile{
+						Data: []byte("UNKNOWN"),
+					},
+				},
+			},
+			want: []song{
+				{
+					path:     "Beck/Guero/04 - Missing.mp3",
+					artist:   "Beck",
+					album:    "Guero",
+					title:    "Missing",
+					track:    4,
+					inferred: true,
+				},
+				{
+					path:     "bad.mp3",
+					title:    "bad",
+					inferred: true,
 				},
 			},
 		},
@@ -129,7 +218,7 @@ func TestSongReaderReadSongs(t *testing.T) {
 			name: "showHash",
 			sr: songReader{
 				pathSuffixes: []string{".mp3"},
-				addHash:      true,
+				hasher:       &songHasher{name: "md5", newHash: md5.New},
 				fsys: fstest.MapFS{
 					"c.mp3": &fstest.MapFile{
 						Data: emptyMP3,
@@ -138,12 +227,13 @@ func TestSongReaderReadSongs(t *testing.T) {
 			},
 			want: []song{
 				{
-					path:   "c.mp3",
-					artist: emptyMp3Artist,
-					album:  emptyMp3Album,
-					title:  emptyMp3Title,
-					track:  1549,
-					hash:   "6f55483b1675c73e08d89b529ba25a65",
+					path:     "c.mp3",
+					artist:   emptyMp3Artist,
+					album:    emptyMp3Album,
+					title:    emptyMp3Title,
+					track:    1549,
+					duration: emptyMp3Duration,
+					hash:     "6f55483b1675c73e08d89b529ba25a65",
 				},
 			},
 		},
@@ -173,7 +263,7 @@ func TestSongReaderReadSongs(t *testing.T) {
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			w := io.Discard
-			got, err := test.sr.readSongs(w)
+			got, _, err := test.sr.readSongs(context.Background(), w)
 			gotErr := err != nil
 			switch {
 			case test.wantErr != gotErr:
@@ -184,3 +274,384 @@ func TestSongReaderReadSongs(t *testing.T) {
 		})
 	}
 }
+
+func TestSongReaderReadSongsCache(t *testing.T) {
+	modTime := time.Date(2022, 7, 15, 0, 0, 0, 0, time.UTC)
+	cache := songCache{
+		entries: map[string]songCacheEntry{
+			"cached.mp3":  {Size: 7, ModTime: modTime.UnixNano(), Artist: "x", Album: "y", Title: "z", Track: 8, Hash: "f00d"},
+			"changed.mp3": {Size: 3, Title: "old"},
+			"deleted.mp3": {Size: 7, Title: "gone"},
+		},
+	}
+	sr := songReader{
+		pathSuffixes: []string{".mp3"},
+		cache:        &cache,
+		fsys: fstest.MapFS{
+			"cached.mp3": &fstest.MapFile{
+				Data:    []byte("UNKNOWN"), // not read because it is cached
+				ModTime: modTime,
+			},
+			"changed.mp3": &fstest.MapFile{
+				Data: emptyMP3,
+			},
+		},
+	}
+	var w strings.Builder
+	got, _, err := sr.readSongs(context.Background(), &w)
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	want := []song{
+		{path: "cached.mp3", artist: "x", album: "y", title: "z", track: 8},
+		{path: "changed.mp3", artist: emptyMp3Artist, album: emptyMp3Album, title: emptyMp3Title, track: 1549, duration: emptyMp3Duration},
+	}
+	sort.Slice(got, func(i, j int) bool { return got[i].path < got[j].path })
+	if fmt.Sprint(want) != fmt.Sprint(got) {
+		t.Errorf("songs not equal: \n wanted: %v \n got:    %v", want, got)
+	}
+	if want, got := "(1 reused from cache, 1 read)", w.String(); !strings.Contains(got, want) {
+		t.Errorf("wanted summary to contain %q, got %q", want, got)
+	}
+	wantPaths := "[cached.mp3 changed.mp3]"
+	var gotPaths []string
+	for p := range cache.entries {
+		gotPaths = append(gotPaths, p)
+	}
+	sort.Strings(gotPaths)
+	if fmt.Sprint(gotPaths) != wantPaths {
+		t.Errorf("cached paths not equal: wanted %v, got %v", wantPaths, gotPaths)
+	}
+	if want, got := "old", cache.entries["changed.mp3"].Title; want == got {
+		t.Errorf("wanted changed song to be updated in cache")
+	}
+}
+
+func TestSongReaderReadSongsRoots(t *testing.T) {
+	cache := newSongCache()
+	sr := songReader{
+		pathSuffixes: []string{".mp3"},
+		cache:        cache,
+		roots: []libraryRoot{
+			{
+				name: ".",
+				fsys: fstest.MapFS{
+					"a.mp3": &fstest.MapFile{Data: emptyMP3},
+				},
+			},
+			{
+				name: "/mnt/drive2",
+				dir:  "../drive2",
+				fsys: fstest.MapFS{
+					"a.mp3":     &fstest.MapFile{Data: emptyMP3},
+					"bad/c.mp3": &fstest.MapFile{Data: []byte("UNKNOWN")},
+				},
+			},
+		},
+	}
+	var w strings.Builder
+	got, loadErrors, err := sr.readSongs(context.Background(), &w)
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	sort.Slice(got, func(i, j int) bool { return got[i].path < got[j].path })
+	var gotPaths []string
+	for _, s := range got {
+		gotPaths = append(gotPaths, s.root+": "+s.path)
+	}
+	if want := "[/mnt/drive2: ../drive2/a.mp3 .: a.mp3]"; want != fmt.Sprint(gotPaths) {
+		t.Errorf("songs not equal: wanted %v, got %v", want, gotPaths)
+	}
+	if len(loadErrors) != 1 || loadErrors[0].path != "../drive2/bad/c.mp3" {
+		t.Errorf("wanted load error for song path in second root, got %v", loadErrors)
+	}
+	var cachedPaths []string
+	for p := range cache.entries {
+		cachedPaths = append(cachedPaths, p)
+	}
+	sort.Strings(cachedPaths)
+	if want := "[../drive2/a.mp3 a.mp3]"; want != fmt.Sprint(cachedPaths) {
+		t.Errorf("cached paths not equal: wanted %v, got %v", want, cachedPaths)
+	}
+}
+
+// openFuncFS calls the function before opening files
+type openFuncFS struct {
+	fs.FS
+	openFunc func(name string) error
+}
+
+func (fsys openFuncFS) Open(name string) (fs.File, error) {
+	if err := fsys.openFunc(name); err != nil {
+		return nil, err
+	}
+	return fsys.FS.Open(name)
+}
+
+func TestSongReaderReadSongsFilter(t *testing.T) {
+	filter, err := newPathFilter([]string{"rock", "jazz"}, []string{".Trashes/"})
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	var opened []string
+	sr := songReader{
+		pathSuffixes: []string{".mp3"},
+		filter:       filter,
+		fsys: openFuncFS{
+			FS: fstest.MapFS{
+				".m3uignore":              &fstest.MapFile{Data: []byte("# junk\n._*\nbackup/\n")},
+				".Trashes/a.mp3":          &fstest.MapFile{Data: emptyMP3},
+				"rock/a.mp3":              &fstest.MapFile{Data: emptyMP3},
+				"rock/._a.mp3":            &fstest.MapFile{Data: emptyMP3},
+				"rock/backup/a.mp3":       &fstest.MapFile{Data: emptyMP3},
+				"rock/live/.m3uignore":    &fstest.MapFile{Data: []byte("*.mp3\n!keep.mp3\n")},
+				"rock/live/a.mp3":         &fstest.MapFile{Data: emptyMP3},
+				"rock/live/keep.mp3":      &fstest.MapFile{Data: emptyMP3},
+				"rock/live/more/b.mp3":    &fstest.MapFile{Data: emptyMP3},
+				"rock/studio/.m3uignore":  &fstest.MapFile{Data: []byte("[bad\n")},
+				"rock/studio/a.mp3":       &fstest.MapFile{Data: emptyMP3},
+				"jazz/a.mp3":              &fstest.MapFile{Data: emptyMP3},
+				"pop/a.mp3":               &fstest.MapFile{Data: emptyMP3},
+				"pop/rock/a.mp3":          &fstest.MapFile{Data: emptyMP3},
+				"other/live/.m3uignore/x": &fstest.MapFile{Data: []byte("not an ignore file")},
+			},
+			openFunc: func(name string) error {
+				opened = append(opened, name)
+				return nil
+			},
+		},
+	}
+	var w strings.Builder
+	got, loadErrors, err := sr.readSongs(context.Background(), &w)
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	var gotPaths []string
+	for _, s := range got {
+		gotPaths = append(gotPaths, s.path)
+	}
+	sort.Strings(gotPaths)
+	if want := "[jazz/a.mp3 pop/rock/a.mp3 rock/a.mp3 rock/live/keep.mp3 rock/studio/a.mp3]"; want != fmt.Sprint(gotPaths) {
+		t.Errorf("songs not equal: \n wanted: %v \n got:    %v", want, gotPaths)
+	}
+	for _, name := range opened {
+		if strings.HasPrefix(name, ".Trashes") || strings.HasPrefix(name, "rock/backup") {
+			t.Errorf("wanted ignored folders to not be read, but %q was opened", name)
+		}
+	}
+	var errorPaths []string
+	for _, le := range loadErrors {
+		errorPaths = append(errorPaths, le.path)
+	}
+	sort.Strings(errorPaths)
+	if want := "[other/live rock/studio]"; want != fmt.Sprint(errorPaths) {
+		t.Errorf("wanted load errors for the ignore files that could not be read: wanted %v, got %v", want, loadErrors)
+	}
+}
+
+func TestSongReaderReadSongsFollowLinks(t *testing.T) {
+	wd := t.TempDir()
+	for _, dir := range []string{"music/local", "shared/beck"} {
+		if err := os.MkdirAll(filepath.Join(wd, dir), 0755); err != nil {
+			t.Fatalf("creating folder: %v", err)
+		}
+	}
+	for _, name := range []string{"music/local/a.mp3", "shared/beck/b.mp3"} {
+		if err := os.WriteFile(filepath.Join(wd, name), emptyMP3, 0644); err != nil {
+			t.Fatalf("creating file: %v", err)
+		}
+	}
+	for link, target := range map[string]string{
+		"music/beck":        "../shared/beck",
+		"music/beck2":       "../shared/beck",
+		"music/loop":        ".",
+		"music/local/c.mp3": "a.mp3",
+		"music/broken":      "missing",
+	} {
+		if err := os.Symlink(target, filepath.Join(wd, link)); err != nil {
+			t.Skipf("symbolic links not supported: %v", err)
+		}
+	}
+	roots, err := newLibraryRoots([]string{"music"}, wd)
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+	tests := []struct {
+		followLinks    bool
+		wantPaths      string
+		wantErrorPaths string
+	}{
+		{false, "[music/local/a.mp3 music/local/c.mp3]", "[]"},
+		{true, "[music/local/a.mp3 shared/beck/b.mp3]", "[music/broken]"},
+	}
+	for _, test := range tests {
+		sr := songReader{
+			pathSuffixes: []string{".mp3"},
+			roots:        roots,
+			followLinks:  test.followLinks,
+		}
+		var w strings.Builder
+		got, loadErrors, err := sr.readSongs(context.Background(), &w)
+		if err != nil {
+			t.Fatalf("unwanted error: %v", err)
+		}
+		var gotPaths, errorPaths []string
+		for _, s := range got {
+			gotPaths = append(gotPaths, s.path)
+		}
+		for _, le := range loadErrors {
+			errorPaths = append(errorPaths, le.path)
+		}
+		sort.Strings(gotPaths)
+		sort.Strings(errorPaths)
+		if test.wantPaths != fmt.Sprint(gotPaths) {
+			t.Errorf("songs when following links is %v not equal: wanted %v, got %v", test.followLinks, test.wantPaths, gotPaths)
+		}
+		if test.wantErrorPaths != fmt.Sprint(errorPaths) {
+			t.Errorf("load errors when following links is %v not equal: wanted %v, got %v", test.followLinks, test.wantErrorPaths, loadErrors)
+		}
+	}
+}
+
+func TestSongReaderReadSongsLoadErrors(t *testing.T) {
+	files := fstest.MapFS{
+		"a.mp3": &fstest.MapFile{Data: emptyMP3},
+		"b.mp3": &fstest.MapFile{Data: emptyMP3},
+		"c.mp3": &fstest.MapFile{Data: make([]byte, 200)}, // no tags
+		"d.mp3": &fstest.MapFile{Data: []byte("ID3\x03\x00\x00\x00\x00\x10\x00")},
+	}
+	openFunc := func(name string) error {
+		if name == "b.mp3" {
+			return fmt.Errorf("mock open error")
+		}
+		return nil
+	}
+	tests := []struct {
+		name      string
+		templates []pathTemplate
+		wantSongs int
+	}{
+		{"skipped", nil, 1},
+		{"inferred", []pathTemplate{*mustPathTemplate(t, "{title}")}, 3},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sr := songReader{
+				pathSuffixes: []string{".mp3"},
+				loadThreads:  2,
+				templates:    test.templates,
+				fsys: openFuncFS{
+					FS:       files,
+					openFunc: openFunc,
+				},
+			}
+			var w strings.Builder
+			got, loadErrors, err := sr.readSongs(context.Background(), &w)
+			sort.Slice(loadErrors, func(i, j int) bool { return loadErrors[i].path < loadErrors[j].path })
+			var gotKinds []string
+			for _, le := range loadErrors {
+				gotKinds = append(gotKinds, le.path+":"+le.kind)
+			}
+			switch {
+			case err != nil:
+				t.Errorf("unwanted error: %v", err)
+			case len(got) != test.wantSongs:
+				t.Errorf("wanted %v songs, got %v", test.wantSongs, got)
+			case fmt.Sprint(gotKinds) != "[b.mp3:open c.mp3:format d.mp3:tags]":
+				t.Errorf("load errors not equal: got %v", loadErrors)
+			case !strings.Contains(loadErrors[0].err, "mock open error"):
+				t.Errorf("wanted open error message, got %q", loadErrors[0].err)
+			case !strings.Contains(w.String(), "with 3 errors"):
+				t.Errorf("wanted error count in summary, got %q", w.String())
+			}
+		})
+	}
+}
+
+func TestSongReaderReadSongsCachedLoadErrors(t *testing.T) {
+	modTime := time.Date(2022, 7, 15, 0, 0, 0, 0, time.UTC)
+	sr := songReader{
+		pathSuffixes: []string{".mp3"},
+		templates:    []pathTemplate{*mustPathTemplate(t, "{title}")},
+		cache:        newSongCache(),
+		fsys: fstest.MapFS{
+			"a.mp3": &fstest.MapFile{Data: emptyMP3, ModTime: modTime},
+			"c.mp3": &fstest.MapFile{Data: make([]byte, 200), ModTime: modTime}, // no tags
+		},
+	}
+	for i, wantReused := range []string{"(0 reused from cache, 2 read)", "(2 reused from cache, 0 read)"} {
+		var w strings.Builder
+		songs, loadErrors, err := sr.readSongs(context.Background(), &w)
+		switch {
+		case err != nil:
+			t.Fatalf("load %v: unwanted error: %v", i+1, err)
+		case len(songs) != 2:
+			t.Errorf("load %v: wanted 2 songs, got %v", i+1, songs)
+		case !strings.Contains(w.String(), wantReused), !strings.Contains(w.String(), "with 1 errors"):
+			t.Errorf("load %v: wanted summary to contain %q and the error count, got %q", i+1, wantReused, w.String())
+		}
+		var out strings.Builder
+		p := playlist{
+			w:          &out,
+			loadErrors: loadErrors,
+		}
+		p.printLoadErrors("")
+		if want := "c.mp3"; !strings.Contains(out.String(), want) || !strings.Contains(out.String(), "tags inferred from path") {
+			t.Errorf("load %v: wanted inferred tags error for %v, got %q", i+1, want, out.String())
+		}
+	}
+}
+
+func TestSongReaderReadSongsCanceled(t *testing.T) {
+	files := fstest.MapFS{}
+	for i := 0; i < 20; i++ {
+		files[fmt.Sprintf("%02d.mp3", i)] = &fstest.MapFile{Data: emptyMP3}
+	}
+	tests := []struct {
+		name      string
+		cancelAt  string
+		wantSongs bool
+	}{
+		{"before walking", "", false},
+		{"while reading", "05.mp3", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if len(test.cancelAt) == 0 {
+				cancel()
+			}
+			cache := songCache{
+				entries: map[string]songCacheEntry{
+					"old.mp3": {Title: "kept"},
+				},
+			}
+			sr := songReader{
+				pathSuffixes: []string{".mp3"},
+				loadThreads:  1,
+				cache:        &cache,
+				fsys: openFuncFS{
+					FS: files,
+					openFunc: func(name string) error {
+						if name == test.cancelAt {
+							cancel()
+						}
+						return nil
+					},
+				},
+			}
+			got, _, err := sr.readSongs(ctx, io.Discard)
+			switch {
+			case !errors.Is(err, context.Canceled):
+				t.Errorf("wanted canceled error, got %v", err)
+			case len(got) >= len(files):
+				t.Errorf("wanted loading to stop early, got %v songs", len(got))
+			case test.wantSongs != (len(got) != 0):
+				t.Errorf("wanted songs read before canceling: %v, got %v", test.wantSongs, got)
+			case len(cache.entries) != len(got)+1:
+				t.Errorf("wanted songs that were read to be added to cache: %v", cache.entries)
+			}
+		})
+	}
+}
@@ -15,6 +15,11 @@ func TestSongMatches(t *testing.T) {
 		{"title match", "19", song{artist: "The Who", album: "Tommy", title: "1921", track: 2}, true},
 		{"album match", "tom", song{artist: "The Who", album: "Tommy", title: "Amazing Journey / Sparks", track: 3}, true},
 		{"artist match", "who", song{artist: "The Who", album: "Tommy", title: "Eyesight To The Blind (The Hawker)", track: 3}, true},
+		{"album artist match", "various", song{albumArtist: "Various Artists"}, true},
+		{"composer match", "townshend", song{composer: "Pete Townshend"}, true},
+		{"genre match", "rock", song{genre: "Rock Opera"}, true},
+		{"comment match", "remaster", song{comment: "2003 remaster"}, true},
+		{"year not checked", "1969", song{year: 1969}, false},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
@@ -66,3 +71,22 @@ func TestSongDisplay(t *testing.T) {
 		})
 	}
 }
+
+func TestSongSortArtist(t *testing.T) {
+	tests := []struct {
+		name string
+		song song
+		want string
+	}{
+		{"none", song{}, ""},
+		{"artist", song{artist: "a"}, "a"},
+		{"album artist", song{artist: "a", albumArtist: "b"}, "b"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.song.sortArtist(); test.want != got {
+				t.Errorf("wanted %q, got %q", test.want, got)
+			}
+		})
+	}
+}
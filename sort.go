@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// trackSortKey compares tracks by a field, in descending order if the key starts with a minus sign
+type trackSortKey struct {
+	name       string
+	compare    func(a, b m3uTrack) int // negative if a is before b
+	descending bool
+}
+
+// trackSortFields compares tracks by the fields that are not song query fields
+var trackSortFields = map[string]func(a, b m3uTrack) int{
+	"display": func(a, b m3uTrack) int {
+		return compareText(a.display, b.display)
+	},
+	"length": func(a, b m3uTrack) int {
+		return compareInts(int64(a.duration), int64(b.duration))
+	},
+}
+
+// librarySortKey orders tracks the same way songs in the library are ordered
+var librarySortKey = trackSortKey{
+	name: "library order",
+	compare: func(a, b m3uTrack) int {
+		return compareSongs(a.song, b.song)
+	},
+}
+
+// sortTracks orders the playlist tracks by keys: sort [-]key[,[-]key...].
+// Keys are compared in order to break ties and keys with a minus sign are sorted in descending order.
+// Tracks that are equal for every key keep their order.
+// Without keys, tracks are sorted in library order.
+func (p *playlist) sortTracks(command string) {
+	keys := []trackSortKey{librarySortKey}
+	if len(strings.TrimSpace(command)) != 0 {
+		var err error
+		keys, err = parseTrackSortKeys(command)
+		if err != nil {
+			p.fail("Error (sort): %v\n", err)
+			return
+		}
+	}
+	tracks := copyTracks(p.tracks)
+	sort.SliceStable(tracks, func(i, j int) bool {
+		for _, k := range keys {
+			c := k.compare(tracks[i], tracks[j])
+			if k.descending {
+				c = -c
+			}
+			if c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+	changed := false
+	for i := range tracks {
+		if tracks[i] != p.tracks[i] {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return
+	}
+	names := make([]string, len(keys))
+	for i, k := range keys {
+		names[i] = k.name
+	}
+	p.record(fmt.Sprintf("sort tracks by %v", strings.Join(names, ",")))
+	p.tracks = tracks
+}
+
+// parseTrackSortKeys reads the comma or space separated keys to sort tracks by
+func parseTrackSortKeys(text string) ([]trackSortKey, error) {
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+	keys := make([]trackSortKey, len(fields))
+	for i, f := range fields {
+		name := strings.ToLower(f)
+		descending := strings.HasPrefix(name, "-")
+		compare, ok := trackSortCompare(strings.TrimPrefix(name, "-"))
+		if !ok {
+			return nil, fmt.Errorf("unknown key %q (wanted one of %v)", f, trackSortKeyNames())
+		}
+		keys[i] = trackSortKey{
+			name:       name,
+			compare:    compare,
+			descending: descending,
+		}
+	}
+	return keys, nil
+}
+
+// trackSortCompare creates a function to compare tracks by the field with the name
+func trackSortCompare(name string) (func(a, b m3uTrack) int, bool) {
+	if compare, ok := trackSortFields[name]; ok {
+		return compare, true
+	}
+	if value, ok := queryTextFields[name]; ok {
+		return func(a, b m3uTrack) int {
+			return compareText(value(a.song), value(b.song))
+		}, true
+	}
+	if value, ok := queryNumberFields[name]; ok {
+		return func(a, b m3uTrack) int {
+			return compareInts(int64(value(a.song)), int64(value(b.song)))
+		}, true
+	}
+	return nil, false
+}
+
+// trackSortKeyNames lists the sorted names of the keys tracks can be sorted by
+func trackSortKeyNames() string {
+	var names []string
+	for name := range trackSortFields {
+		names = append(names, name)
+	}
+	for name := range queryTextFields {
+		names = append(names, name)
+	}
+	for name := range queryNumberFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// compareText compares the text, ignoring case
+func compareText(a, b string) int {
+	return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+}
+
+// compareInts compares the numbers
+func compareInts(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestPlaylistSortTracks(t *testing.T) {
+	tracks := []m3uTrack{
+		{display: "1", song: song{path: "c.mp3", artist: "b", album: "y", title: "Zebra", track: 2, duration: time.Minute}},
+		{display: "2", song: song{path: "a.mp3", artist: "a", album: "x", title: "apple", track: 10}},
+		{display: "3", song: song{path: "B.mp3", artist: "B", album: "y", title: "Mango", track: 1, duration: time.Second}},
+		{display: "4", song: song{path: "d.mp3", artist: "a", album: "x", title: "kiwi", track: 2, duration: time.Hour}},
+	}
+	tests := []struct {
+		command     string
+		want        string
+		wantErr     bool
+		wantChanged bool
+	}{
+		{"", "[3 4 2 1]", false, true}, // library order, which is case-sensitive
+		{"title", "[2 4 3 1]", false, true},
+		{"-title", "[1 3 4 2]", false, true},
+		{"track", "[3 1 4 2]", false, true},
+		{"-track", "[2 1 4 3]", false, true},
+		{"artist", "[2 4 1 3]", false, true}, // stable, ignoring case
+		{"artist,-track", "[2 4 1 3]", false, true},
+		{"ARTIST, track", "[4 2 3 1]", false, true},
+		{"album -length", "[4 2 1 3]", false, true},
+		{"path", "[2 3 1 4]", false, true},
+		{"display", "[1 2 3 4]", false, false},
+		{"-display", "[4 3 2 1]", false, true},
+		{"rating", "[1 2 3 4]", true, false},
+		{"artist,", "[2 4 1 3]", false, true},
+	}
+	for _, test := range tests {
+		t.Run(test.command, func(t *testing.T) {
+			var w bytes.Buffer
+			p := playlist{
+				tracks: copyTracks(tracks),
+				w:      &w,
+			}
+			p.sortTracks(test.command)
+			switch {
+			case test.wantErr != (w.Len() != 0):
+				t.Errorf("wanted error: %v, got %q", test.wantErr, w.String())
+			case test.want != fmt.Sprint(trackDisplays(p.tracks)):
+				t.Errorf("sorted tracks not equal: wanted %v, got %v", test.want, trackDisplays(p.tracks))
+			case test.wantChanged != (len(p.history.undo) == 1):
+				t.Errorf("wanted sort to be recorded: %v, got %v", test.wantChanged, p.history.undo)
+			}
+		})
+	}
+	t.Run("undo", func(t *testing.T) {
+		p := playlist{
+			tracks: copyTracks(tracks),
+		}
+		p.sortTracks("-track")
+		p.undo("")
+		if want, got := "[1 2 3 4]", fmt.Sprint(trackDisplays(p.tracks)); want != got {
+			t.Errorf("tracks not equal after undo: wanted %v, got %v", want, got)
+		}
+	})
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const xspfNamespace = "http://xspf.org/ns/0/"
+
+type (
+	// xspfPlaylist is the xml of a XML Shareable Playlist Format file.
+	// Elements that are not used, such as extensions, are ignored.
+	xspfPlaylist struct {
+		XMLName   xml.Name    `xml:"playlist"`
+		Version   string      `xml:"version,attr"`
+		Namespace string      `xml:"xmlns,attr,omitempty"`
+		Tracks    []xspfTrack `xml:"trackList>track"`
+	}
+	xspfTrack struct {
+		Locations []string `xml:"location"`
+		Title     string   `xml:"title,omitempty"`
+		Creator   string   `xml:"creator,omitempty"`
+		Album     string   `xml:"album,omitempty"`
+		TrackNum  int      `xml:"trackNum,omitempty"`
+		Duration  int64    `xml:"duration,omitempty"` // milliseconds
+	}
+)
+
+// readXSPF reads the playlist tracks from a xspf file, updating the playlist contain all valid songs in the file.
+// The first location of each track that is a song is used.
+// The titles of tracks are their display names.
+// Tracks that are not songs keep their creator, album, track number, and duration, so they can be relinked by their tags.
+func (p *playlist) readXSPF(r io.Reader) (n int64, err error) {
+	b, err := io.ReadAll(r)
+	n = int64(len(b))
+	if err != nil {
+		p.tracks = nil
+		return n, fmt.Errorf("reading playlist file: %v", err)
+	}
+	var x xspfPlaylist
+	if err := xml.Unmarshal(b, &x); err != nil {
+		p.tracks = nil
+		return n, fmt.Errorf("parsing xspf playlist: %v", err)
+	}
+	tr := p.newTrackResolver()
+	for _, xt := range x.Tracks {
+		tr.add(p.xspfTrackPath(xt, tr), strings.TrimSpace(xt.Title))
+		if t := &tr.tracks[len(tr.tracks)-1]; t.missing {
+			t.song = xspfTrackSong(t.path, xt)
+		}
+	}
+	p.tracks = tr.tracks
+	return n, tr.err()
+}
+
+// xspfTrackSong is the song of a track that was not found, from the fields of the track in the xspf file.
+// The title of the track might be its display name, which starts with the artist.
+func xspfTrackSong(songPath string, xt xspfTrack) song {
+	artist := strings.TrimSpace(xt.Creator)
+	title := strings.TrimSpace(xt.Title)
+	if len(artist) != 0 {
+		title = strings.TrimPrefix(title, artist+" - ")
+	}
+	s := song{
+		path:     songPath,
+		artist:   artist,
+		album:    strings.TrimSpace(xt.Album),
+		title:    title,
+		track:    xt.TrackNum,
+		duration: time.Duration(xt.Duration) * time.Millisecond,
+	}
+	return s
+}
+
+// xspfTrackPath finds the first location of the track that is a song, or the path of the first file location if none are songs.
+// Relative references are resolved from the folder of the playlist file.
+// File URIs outside the library roots are absolute paths, and other locations, such as web addresses, are kept as they are.
+func (p playlist) xspfTrackPath(xt xspfTrack, tr *trackResolver) string {
+	for _, l := range xt.Locations {
+		if songPath, err := p.xspfResolvedPath(l, tr); err == nil {
+			if _, ok := tr.songPaths[songPath]; ok {
+				return songPath
+			}
+		}
+	}
+	for _, l := range xt.Locations {
+		if songPath, err := p.xspfResolvedPath(l, tr); err == nil {
+			return songPath
+		}
+		if u, err := url.Parse(strings.TrimSpace(l)); err == nil && u.Scheme == "file" {
+			return xspfFilePath(u)
+		}
+	}
+	if len(xt.Locations) != 0 {
+		return strings.TrimSpace(xt.Locations[0])
+	}
+	return ""
+}
+
+// xspfResolvedPath converts the location of a track to the path of its song, resolving relative references from the folder of the playlist file
+func (p playlist) xspfResolvedPath(location string, tr *trackResolver) (string, error) {
+	songPath, err := p.xspfSongPath(location)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(strings.ToLower(strings.TrimSpace(location)), "file:") {
+		songPath = tr.songPath(songPath)
+	}
+	return songPath, nil
+}
+
+// writeXSPF writes the tracks of the playlist as a xspf file.
+// Locations are percent-encoded paths relative to the folder of the playlist file, or file URIs for absolute paths.
+func (p playlist) writeXSPF(w io.Writer) (n int64, err error) {
+	x := xspfPlaylist{
+		Version:   "1",
+		Namespace: xspfNamespace,
+		Tracks:    make([]xspfTrack, len(p.tracks)),
+	}
+	for i, t := range p.tracks {
+		entry := p.entryPath(t.path)
+		location := url.URL{Path: entry}
+		if isAbsolutePath(entry) {
+			location.Scheme = "file"
+			if !strings.HasPrefix(entry, "/") {
+				location.Path = "/" + entry // windows drive letter
+			}
+		}
+		x.Tracks[i] = xspfTrack{
+			Locations: []string{location.String()},
+			Title:     t.display,
+			Creator:   t.artist,
+			Album:     t.album,
+			TrackNum:  t.track,
+			Duration:  int64(t.duration / time.Millisecond),
+		}
+	}
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(x); err != nil {
+		return 0, fmt.Errorf("encoding xspf playlist: %v", err)
+	}
+	buf.WriteString("\n")
+	return buf.WriteTo(w)
+}
+
+// xspfSongPath converts the location of a track to the path of the song relative to the working directory.
+// Locations can be relative references or file URIs in a library root or the working directory.
+func (p playlist) xspfSongPath(location string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(location))
+	if err != nil {
+		return "", fmt.Errorf("parsing location: %v", err)
+	}
+	switch u.Scheme {
+	case "":
+		return path.Clean(u.Path), nil
+	case "file":
+		songPath := xspfFilePath(u)
+		for _, r := range p.libraryRoots {
+			if name, ok := folderSubpath(filepath.ToSlash(r.abs), songPath); ok {
+				return r.songPath(name), nil
+			}
+		}
+		if name, ok := folderSubpath(p.root, songPath); ok {
+			return name, nil
+		}
+		return "", fmt.Errorf("location not in a library root or %v: %q", p.root, location)
+	}
+	return "", fmt.Errorf("location must be a file: %q", location)
+}
+
+// xspfFilePath is the absolute, slash-separated path of the file URI
+func xspfFilePath(u *url.URL) string {
+	filePath := u.Path
+	if len(filePath) > 2 && filePath[0] == '/' && filePath[2] == ':' {
+		filePath = filePath[1:] // windows drive letter: file:///C:/Music/song.mp3
+	}
+	return filePath
+}
+
+// folderSubpath finds the path of the file relative to the absolute, slash-separated folder, returning false if the file is not in the folder
+func folderSubpath(folder, file string) (string, bool) {
+	if len(folder) == 0 {
+		return "", false
+	}
+	folder = strings.TrimSuffix(folder, "/") + "/"
+	if !strings.HasPrefix(file, folder) {
+		return "", false
+	}
+	return file[len(folder):], true
+}
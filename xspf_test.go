@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"testing/iotest"
+	"time"
+)
+
+func TestPlaylistReadXSPF(t *testing.T) {
+	songs := []song{
+		{path: "Artist A/My Song #1.mp3", artist: "Artist A", title: "My Song #1", track: 1},
+		{path: "b/c.mp3", artist: "b", title: "c", track: 2},
+	}
+	tests := []struct {
+		name       string
+		data       string
+		wantTracks []m3uTrack
+		wantErr    bool
+	}{
+		{
+			name:    "not xml",
+			data:    "#EXTM3U",
+			wantErr: true,
+		},
+		{
+			name: "empty",
+			data: `<?xml version="1.0" encoding="UTF-8"?><playlist version="1" xmlns="http://xspf.org/ns/0/"><trackList/></playlist>`,
+		},
+		{
+			name: "ok",
+			data: `<?xml version="1.0" encoding="UTF-8"?>
+<playlist version="1" xmlns="http://xspf.org/ns/0/" xmlns:vlc="http://www.videolan.org/vlc/playlist/ns/0/">
+  <title>Road Trip</title>
+  <trackList>
+    <track>
+      <location>file:///media/usb/Artist%20A/My%20Song%20%231.mp3</location>
+      <title>Custom Name</title>
+      <creator>Artist A</creator>
+      <album>?</album>
+      <trackNum>1</trackNum>
+      <duration>185000</duration>
+      <extension application="http://www.videolan.org/vlc/playlist/0">
+        <vlc:id>0</vlc:id>
+      </extension>
+    </track>
+    <track>
+      <location>b/c.mp3</location>
+    </track>
+  </trackList>
+  <extension application="http://www.videolan.org/vlc/playlist/0">
+    <vlc:item tid="0"/>
+  </extension>
+</playlist>`,
+			wantTracks: []m3uTrack{
+				{song: songs[0], display: "Custom Name"},
+				{song: songs[1], display: "b - c"},
+			},
+		},
+		{
+			name: "no namespace, first location that is a song",
+			data: `<playlist version="1"><trackList><track>` +
+				`<location>http://example.com/c.mp3</location>` +
+				`<location>file:///elsewhere/b/c.mp3</location>` +
+				`<location>./b/../b/c.mp3</location>` +
+				`<title>  c  </title>` +
+				`</track></trackList></playlist>`,
+			wantTracks: []m3uTrack{
+				{song: songs[1], display: "c"},
+			},
+		},
+		{
+			name: "missing songs",
+			data: `<playlist version="1"><trackList>` +
+				`<track><location>file:///elsewhere/b/c.mp3</location></track>` +
+				`<track><location>Artist%20A/My%20Song%20%231.mp3</location></track>` +
+				`<track><title>no location</title></track>` +
+				`</trackList></playlist>`,
+			wantTracks: []m3uTrack{
+				{song: song{path: "/elsewhere/b/c.mp3"}, missing: true},
+				{song: songs[0], display: "Artist A - My Song #1"},
+				{song: song{title: "no location"}, display: "no location", missing: true},
+			},
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := playlist{
+				songs:  songs,
+				tracks: []m3uTrack{{display: "old"}},
+				root:   "/media/usb",
+			}
+			want := playlist{
+				songs:  songs,
+				tracks: test.wantTracks,
+			}
+			_, err := p.readXSPF(strings.NewReader(test.data))
+			if test.wantErr != (err != nil) {
+				t.Errorf("wanted error: %v, got %v", test.wantErr, err)
+			}
+			checkPlaylistsEqual(t, want, p)
+		})
+	}
+	t.Run("read error", func(t *testing.T) {
+		r := iotest.ErrReader(fmt.Errorf("mock read playlist file error"))
+		p := playlist{
+			tracks: []m3uTrack{{}},
+		}
+		if _, err := p.readXSPF(r); err == nil {
+			t.Errorf("wanted error")
+		}
+		if len(p.tracks) != 0 {
+			t.Errorf("wanted tracks to be cleared after broken read")
+		}
+	})
+}
+
+func TestPlaylistXSPFSongPath(t *testing.T) {
+	musicRoot := libraryRoot{dir: "../../mnt/music", abs: filepath.FromSlash("/mnt/music")}
+	podcastsRoot := libraryRoot{dir: "podcasts", abs: filepath.FromSlash("/home/me/podcasts")}
+	tests := []struct {
+		name     string
+		root     string
+		roots    []libraryRoot
+		location string
+		want     string
+		wantErr  bool
+	}{
+		{"relative", "/music", nil, "a/b.mp3", "a/b.mp3", false},
+		{"relative escaped", "/music", nil, "a%20b/c%3F.mp3", "a b/c?.mp3", false},
+		{"relative cleaned", "/music", nil, "./a/../b.mp3", "b.mp3", false},
+		{"file", "/music", nil, "file:///music/a/b.mp3", "a/b.mp3", false},
+		{"file root with slash", "/music/", nil, "file:///music/a/b.mp3", "a/b.mp3", false},
+		{"file escaped", "/my music", nil, "file:///my%20music/a%23b.mp3", "a#b.mp3", false},
+		{"file windows", "C:/Users/me/Music", nil, "file:///C:/Users/me/Music/a.mp3", "a.mp3", false},
+		{"file outside root", "/music", nil, "file:///other/a.mp3", "", true},
+		{"file similar root", "/music", nil, "file:///music2/a.mp3", "", true},
+		{"file no root", "", nil, "file:///music/a.mp3", "", true},
+		{"http", "/music", nil, "http://example.com/a.mp3", "", true},
+		{"bad url", "/music", nil, "%zz", "", true},
+		{"file in library root", "/home/me", []libraryRoot{podcastsRoot, musicRoot}, "file:///mnt/music/a/b.mp3", "../../mnt/music/a/b.mp3", false},
+		{"file in library root in working directory", "/home/me", []libraryRoot{musicRoot, podcastsRoot}, "file:///home/me/podcasts/a.mp3", "podcasts/a.mp3", false},
+		{"file in working directory, not library root", "/home/me", []libraryRoot{musicRoot}, "file:///home/me/a.mp3", "a.mp3", false},
+		{"file outside library roots", "/home/me", []libraryRoot{musicRoot}, "file:///mnt/other/a.mp3", "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := playlist{root: test.root, libraryRoots: test.roots}
+			got, err := p.xspfSongPath(test.location)
+			switch {
+			case test.wantErr:
+				if err == nil {
+					t.Errorf("wanted error")
+				}
+			case err != nil:
+				t.Errorf("unwanted error: %v", err)
+			case test.want != got:
+				t.Errorf("paths not equal: wanted %q, got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestPlaylistWriteXSPF(t *testing.T) {
+	p := playlist{
+		tracks: []m3uTrack{
+			{display: "Track <1>", song: song{path: "a b/c#1.mp3", artist: "x & y", album: "z", track: 3, duration: 185500 * time.Millisecond}},
+			{display: "track 2", song: song{path: "r/b.mp3"}},
+		},
+	}
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<playlist version="1" xmlns="http://xspf.org/ns/0/">
+  <trackList>
+    <track>
+      <location>a%20b/c%231.mp3</location>
+      <title>Track &lt;1&gt;</title>
+      <creator>x &amp; y</creator>
+      <album>z</album>
+      <trackNum>3</trackNum>
+      <duration>185500</duration>
+    </track>
+    <track>
+      <location>r/b.mp3</location>
+      <title>track 2</title>
+    </track>
+  </trackList>
+</playlist>
+`
+	var buf bytes.Buffer
+	n, err := p.writeXSPF(&buf)
+	switch {
+	case err != nil:
+		t.Errorf("unwanted error: %v", err)
+	case want != buf.String():
+		t.Errorf("xspf files not equal: \n wanted: %q \n got:    %q", want, buf.String())
+	case int64(len(want)) != n:
+		t.Errorf("wanted %v bytes to be written, got %v", len(want), n)
+	}
+	t.Run("round trip", func(t *testing.T) {
+		p2 := playlist{
+			songs: []song{p.tracks[0].song, p.tracks[1].song},
+		}
+		if _, err := p2.readXSPF(&buf); err != nil {
+			t.Fatalf("unwanted error: %v", err)
+		}
+		checkPlaylistsEqual(t, playlist{songs: p2.songs, tracks: p.tracks}, p2)
+	})
+}
+
+func TestPlaylistXSPFMissingTracks(t *testing.T) {
+	songs := []song{
+		{path: "new/My Song.mp3", artist: "x", title: "My Song"},
+	}
+	src := `<playlist version="1"><trackList>` +
+		`<track><location>My%20Song.mp3</location></track>` +
+		`<track><location>file:///m/lists/Other%20Song.mp3</location></track>` +
+		`<track><location>file:///elsewhere/x%20y.mp3</location></track>` +
+		`</trackList></playlist>`
+	p := playlist{
+		songs: songs,
+		fsys: MockPlaylistFS{
+			FS: fstest.MapFS{
+				"lists/mix.xspf": &fstest.MapFile{Data: []byte(src)},
+			},
+		},
+		w:    io.Discard,
+		root: "/m",
+	}
+	p.load("lists/mix.xspf")
+	wantPaths := "[lists/My Song.mp3 lists/Other Song.mp3 /elsewhere/x y.mp3]"
+	var gotPaths []string
+	for _, t := range p.tracks {
+		gotPaths = append(gotPaths, t.path)
+	}
+	if wantPaths != fmt.Sprint(gotPaths) {
+		t.Fatalf("missing track paths not equal: \n wanted: %v \n got:    %v", wantPaths, gotPaths)
+	}
+	t.Run("m3u", func(t *testing.T) {
+		var buf bytes.Buffer
+		if _, err := p.WriteTo(&buf); err != nil {
+			t.Fatalf("unwanted error: %v", err)
+		}
+		want := "#EXTM3U\r\n" +
+			"#EXTINF:0, \r\nMy Song.mp3\r\n" +
+			"#EXTINF:0, \r\nOther Song.mp3\r\n" +
+			"#EXTINF:0, \r\n/elsewhere/x y.mp3\r\n"
+		if got := buf.String(); want != got {
+			t.Errorf("m3u files not equal: \n wanted: %q \n got:    %q", want, got)
+		}
+	})
+	t.Run("xspf", func(t *testing.T) {
+		var buf bytes.Buffer
+		if _, err := p.writeXSPF(&buf); err != nil {
+			t.Fatalf("unwanted error: %v", err)
+		}
+		for _, want := range []string{
+			"<location>My%20Song.mp3</location>",
+			"<location>Other%20Song.mp3</location>",
+			"<location>file:///elsewhere/x%20y.mp3</location>",
+		} {
+			if !strings.Contains(buf.String(), want) {
+				t.Errorf("wanted %v in xspf file: %q", want, buf.String())
+			}
+		}
+	})
+	t.Run("relink", func(t *testing.T) {
+		if candidates := p.relinkCandidates(p.tracks[0]); len(candidates) == 0 || candidates[0].path != songs[0].path {
+			t.Errorf("wanted %q to be suggested for the missing track, got %v", songs[0].path, candidates)
+		}
+	})
+}
+
+func TestPlaylistXSPFMissingTrackTags(t *testing.T) {
+	songs := []song{
+		{path: "a/b.mp3", artist: "x", title: "z"},
+		{path: "new/y.mp3", artist: "Artist Y", title: "Title Z"},
+	}
+	src := `<playlist version="1"><trackList>` +
+		`<track><location>old/song.mp3</location><title>Artist Y - Title Z</title><creator>Artist Y</creator>` +
+		`<album>Album W</album><trackNum>3</trackNum><duration>61500</duration></track>` +
+		`</trackList></playlist>`
+	p := playlist{
+		songs: songs,
+		fsys: MockPlaylistFS{
+			FS: fstest.MapFS{
+				"mix.xspf": &fstest.MapFile{Data: []byte(src)},
+			},
+		},
+		w: io.Discard,
+	}
+	p.load("mix.xspf")
+	want := m3uTrack{
+		song: song{
+			path:     "old/song.mp3",
+			artist:   "Artist Y",
+			album:    "Album W",
+			title:    "Title Z",
+			track:    3,
+			duration: 61500 * time.Millisecond,
+		},
+		display: "Artist Y - Title Z",
+		missing: true,
+	}
+	if len(p.tracks) != 1 || fmt.Sprint(want) != fmt.Sprint(p.tracks[0]) {
+		t.Fatalf("missing tracks not equal: \n wanted: %v \n got:    %v", want, p.tracks)
+	}
+	candidates := p.relinkCandidates(p.tracks[0])
+	switch {
+	case len(candidates) == 0 || candidates[0].path != songs[1].path:
+		t.Errorf("wanted %q to be suggested for the missing track, got %v", songs[1].path, candidates)
+	case !strings.Contains(fmt.Sprint(candidates[0].reasons), "same artist and title"):
+		t.Errorf("wanted candidate to match by artist and title, got reasons %v", candidates[0].reasons)
+	}
+}
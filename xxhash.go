@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+)
+
+// the primes of the XXH64 algorithm
+const (
+	xxPrime1 uint64 = 11400714785074694791
+	xxPrime2 uint64 = 14029467366897019727
+	xxPrime3 uint64 = 1609587929392839161
+	xxPrime4 uint64 = 9650029242287828579
+	xxPrime5 uint64 = 2870177450012600261
+)
+
+// xxHash64 is the 64-bit xxHash of the data written to it, with a seed of zero.
+// It reads the data eight bytes at a time in stripes of 32 bytes, so it is much faster than byte-at-a-time hashes, but it is not cryptographic.
+type xxHash64 struct {
+	v     [4]uint64 // the accumulators of the lanes of the stripes
+	total uint64    // the number of bytes written
+	mem   [32]byte  // the start of a stripe that has not been filled
+	n     int       // the number of bytes in mem
+}
+
+// newXXHash64 creates a 64-bit xxHash
+func newXXHash64() hash.Hash64 {
+	var x xxHash64
+	x.Reset()
+	return &x
+}
+
+// Reset clears the data written to the hash
+func (x *xxHash64) Reset() {
+	p1, p2 := xxPrime1, xxPrime2 // variables, so the sums overflow like the algorithm expects
+	x.v = [4]uint64{p1 + p2, p2, 0, -p1}
+	x.total = 0
+	x.n = 0
+}
+
+// Size is the number of bytes of the hash
+func (x *xxHash64) Size() int {
+	return 8
+}
+
+// BlockSize is the number of bytes of the stripes that are hashed together
+func (x *xxHash64) BlockSize() int {
+	return 32
+}
+
+// Write adds the data to the hash, which never fails
+func (x *xxHash64) Write(b []byte) (int, error) {
+	n := len(b)
+	x.total += uint64(n)
+	if x.n+len(b) < len(x.mem) {
+		x.n += copy(x.mem[x.n:], b)
+		return n, nil
+	}
+	if x.n != 0 {
+		c := copy(x.mem[x.n:], b)
+		x.stripe(x.mem[:])
+		b = b[c:]
+		x.n = 0
+	}
+	for ; len(b) >= len(x.mem); b = b[len(x.mem):] {
+		x.stripe(b)
+	}
+	x.n = copy(x.mem[:], b)
+	return n, nil
+}
+
+// stripe adds the first 32 bytes of the data to the accumulators
+func (x *xxHash64) stripe(b []byte) {
+	x.v[0] = xxRound(x.v[0], binary.LittleEndian.Uint64(b[0:8]))
+	x.v[1] = xxRound(x.v[1], binary.LittleEndian.Uint64(b[8:16]))
+	x.v[2] = xxRound(x.v[2], binary.LittleEndian.Uint64(b[16:24]))
+	x.v[3] = xxRound(x.v[3], binary.LittleEndian.Uint64(b[24:32]))
+}
+
+// Sum appends the big-endian hash to the data
+func (x *xxHash64) Sum(b []byte) []byte {
+	var s [8]byte
+	binary.BigEndian.PutUint64(s[:], x.Sum64())
+	return append(b, s[:]...)
+}
+
+// Sum64 is the hash of the data that was written
+func (x *xxHash64) Sum64() uint64 {
+	var h uint64
+	if x.total >= uint64(len(x.mem)) {
+		v := x.v
+		h = bits.RotateLeft64(v[0], 1) + bits.RotateLeft64(v[1], 7) + bits.RotateLeft64(v[2], 12) + bits.RotateLeft64(v[3], 18)
+		for _, vi := range v {
+			h ^= xxRound(0, vi)
+			h = h*xxPrime1 + xxPrime4
+		}
+	} else {
+		h = xxPrime5
+	}
+	h += x.total
+	b := x.mem[:x.n]
+	for ; len(b) >= 8; b = b[8:] {
+		h ^= xxRound(0, binary.LittleEndian.Uint64(b))
+		h = bits.RotateLeft64(h, 27)*xxPrime1 + xxPrime4
+	}
+	if len(b) >= 4 {
+		h ^= uint64(binary.LittleEndian.Uint32(b)) * xxPrime1
+		h = bits.RotateLeft64(h, 23)*xxPrime2 + xxPrime3
+		b = b[4:]
+	}
+	for _, c := range b {
+		h ^= uint64(c) * xxPrime5
+		h = bits.RotateLeft64(h, 11) * xxPrime1
+	}
+	h ^= h >> 33
+	h *= xxPrime2
+	h ^= h >> 29
+	h *= xxPrime3
+	h ^= h >> 32
+	return h
+}
+
+// xxRound mixes eight bytes of input into the accumulator
+func xxRound(acc, input uint64) uint64 {
+	acc += input * xxPrime2
+	acc = bits.RotateLeft64(acc, 31)
+	return acc * xxPrime1
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestXXHash64(t *testing.T) {
+	long := append(bytes.Repeat(func() []byte {
+		b := make([]byte, 256)
+		for i := range b {
+			b[i] = byte(i)
+		}
+		return b
+	}(), 4), "xyz"...)
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"empty", nil, "ef46db3751d8e999"},
+		{"one byte", []byte("a"), "d24ec4f1a98c6e5b"},
+		{"short", []byte("abc"), "44bc2cf5ad770999"},
+		{"one stripe and a partial stripe", []byte("Nobody inspects the spammish repetition"), "fbcea83c8a378bf1"},
+		{"many stripes", long, "e146cb31b65bc21a"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, chunkSize := range []int{1, 3, 31, 32, 33, len(test.data) + 1} {
+				h := newXXHash64()
+				for b := test.data; len(b) != 0; {
+					n := chunkSize
+					if n > len(b) {
+						n = len(b)
+					}
+					h.Write(b[:n])
+					b = b[n:]
+				}
+				if got := fmt.Sprintf("%x", h.Sum(nil)); test.want != got {
+					t.Errorf("hashes not equal when written %v bytes at a time: wanted %v, got %v", chunkSize, test.want, got)
+				}
+			}
+		})
+	}
+	t.Run("reset", func(t *testing.T) {
+		h := newXXHash64()
+		h.Write([]byte("Nobody inspects the spammish repetition"))
+		h.Reset()
+		h.Write([]byte("abc"))
+		if want, got := "44bc2cf5ad770999", fmt.Sprintf("%x", h.Sum(nil)); want != got {
+			t.Errorf("hashes not equal after reset: wanted %v, got %v", want, got)
+		}
+	})
+}